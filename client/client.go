@@ -2,7 +2,7 @@ package client
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
@@ -10,10 +10,14 @@ import (
 	"net/http"
 	"sort"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
+	// Accessed atomically, kept first for 64 bit alignment
+	limiterWait int64
+
 	ip            string
 	port          string
 	nodeID        string
@@ -22,14 +26,21 @@ type Client struct {
 	keyIdentifier string
 	address       string
 	httpClient    http.Client
+	timeouts      *Timeouts
+	limiter       Limiter
 }
 
+// Creates a client that verifies the node's certificate against the system roots. Use
+// NewClientWithTransport with a TLS config from NewTLSConfig for other verification options.
 func NewClient(ip, port, nodeID, nodeSignature, addressBase, keyIdentifier string) *Client {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+	return NewClientWithTransport(ip, port, nodeID, nodeSignature, addressBase, keyIdentifier, &http.Transport{})
+}
+
+// Creates a client that sends its requests through the given transport, which may be shared
+// with other clients of the same node
+func NewClientWithTransport(ip, port, nodeID, nodeSignature, addressBase, keyIdentifier string, transport http.RoundTripper) *Client {
 	client := http.Client{
-		Transport: tr,
+		Transport: transport,
 	}
 
 	return &Client{
@@ -121,14 +132,7 @@ func (c *Client) VerifyNodeID() error {
 
 	request.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationNodeID, request)
 	if err != nil {
 		return err
 	}
@@ -414,14 +418,7 @@ func (c *Client) GetUnspentTransactionOutputs(addressKeyIdentifier string) ([]*T
 
 	request.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationOutputs, request)
 	if err != nil {
 		return nil, err
 	}
@@ -452,14 +449,7 @@ func (c *Client) GetPrivateKey(address string) (string, error) {
 
 	request.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return "", err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationPrivateKey, request)
 	if err != nil {
 		return "", err
 	}
@@ -500,14 +490,7 @@ func (c *Client) SignTransaction(unsignedTx *UnsignedTransaction, keyMap map[str
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Content-Length", strconv.Itoa(len(signRequestJson)))
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationSign, request)
 	if err != nil {
 		return nil, err
 	}
@@ -559,14 +542,7 @@ func (c *Client) SubmitTransaction(tx *Transaction) error {
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Content-Length", strconv.Itoa(len(submitRequestJson)))
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationSubmit, request)
 	if err != nil {
 		return err
 	}
@@ -595,14 +571,7 @@ func (c *Client) GetAddressInfo(address string) (*AddressInfo, error) {
 
 	request.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationAddressInfo, request)
 	if err != nil {
 		return nil, err
 	}
@@ -634,14 +603,7 @@ func (c *Client) GetBalance(address string) (uint, uint, error) {
 
 	request.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationBalance, request)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -666,14 +628,7 @@ func (c *Client) GenerateNewAddress() (*AddressInfo, error) {
 
 	request.URL.RawQuery = q.Encode()
 
-	resp, err := c.httpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	respContent, err := ioutil.ReadAll(resp.Body)
+	respContent, err := c.do(OperationGenerateAddress, request)
 	if err != nil {
 		return nil, err
 	}
@@ -686,6 +641,63 @@ func (c *Client) GenerateNewAddress() (*AddressInfo, error) {
 	return info, nil
 }
 
+// Sets the request timeouts. Must be called before the client is used.
+func (c *Client) SetTimeouts(timeouts *Timeouts) {
+	c.timeouts = timeouts
+}
+
+// Sets the limiter that every request waits for. Must be called before the client is used.
+func (c *Client) SetLimiter(limiter Limiter) {
+	c.limiter = limiter
+}
+
+// Total time the requests of the client waited for the limiter
+func (c *Client) LimiterWait() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.limiterWait))
+}
+
+// Waits for the limiter, then performs the request within the timeout of the operation and reads the
+// response. The timeout does not include the time spent waiting for the limiter.
+func (c *Client) do(operation string, request *http.Request) ([]byte, error) {
+	_, respContent, err := c.send(operation, request)
+	return respContent, err
+}
+
+// Like do, and also returns the status code of the response
+func (c *Client) send(operation string, request *http.Request) (int, []byte, error) {
+	if c.limiter != nil {
+		atomic.AddInt64(&c.limiterWait, int64(c.limiter.Wait(operation)))
+	}
+
+	request = withOperation(request, operation)
+	timeout := c.timeouts.timeout(operation)
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		defer cancel()
+		request = request.WithContext(ctx)
+	}
+
+	resp, err := c.httpClient.Do(request)
+	if err != nil {
+		if request.Context().Err() == context.DeadlineExceeded {
+			return 0, nil, &TimeoutError{Operation: operation, Timeout: timeout}
+		}
+		return 0, nil, err
+	}
+
+	defer resp.Body.Close()
+
+	respContent, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		if request.Context().Err() == context.DeadlineExceeded {
+			return resp.StatusCode, nil, &TimeoutError{Operation: operation, Timeout: timeout}
+		}
+		return resp.StatusCode, nil, err
+	}
+
+	return resp.StatusCode, respContent, nil
+}
+
 func (c *Client) getBaseUrl() string {
 	return fmt.Sprintf("https://%s:%s/api/%s/%s", c.ip, c.port, c.nodeID, c.nodeSignature)
 }
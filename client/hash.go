@@ -0,0 +1,232 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Offsets of the checksum bits in a 288 bit chash, derived from the digits of pi
+var chash288Offsets = calcChashOffsets(288)
+
+func base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	encoded := make([]byte, 0, len(data)*138/100+1)
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+func base58Decode(encoded string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(58)
+
+	for _, c := range []byte(encoded) {
+		digit := bytes.IndexByte([]byte(base58Alphabet), c)
+		if digit < 0 {
+			return nil, fmt.Errorf("Invalid base58 character %q", c)
+		}
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	decoded := value.Bytes()
+	for _, c := range []byte(encoded) {
+		if c != base58Alphabet[0] {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+
+	return decoded, nil
+}
+
+// Builds the canonical source string of a JSON value the way the node's object hash does:
+// type-tagged components, object keys in sorted order, joined with NUL characters.
+// Null values and empty arrays are left out.
+func sourceString(value interface{}) (string, error) {
+	components := make([]string, 0)
+	if err := extractComponents(value, &components); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for i, component := range components {
+		if i > 0 {
+			buf.WriteByte(0)
+		}
+		buf.WriteString(component)
+	}
+
+	return buf.String(), nil
+}
+
+func extractComponents(value interface{}, components *[]string) error {
+	switch v := value.(type) {
+	case string:
+		*components = append(*components, "s", v)
+	case json.Number:
+		*components = append(*components, "n", v.String())
+	case bool:
+		*components = append(*components, "b", strconv.FormatBool(v))
+	case []interface{}:
+		*components = append(*components, "[")
+		for _, item := range v {
+			if err := extractComponents(item, components); err != nil {
+				return err
+			}
+		}
+		*components = append(*components, "]")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key, item := range v {
+			if item == nil {
+				continue
+			}
+			if list, ok := item.([]interface{}); ok && len(list) == 0 {
+				continue
+			}
+			keys = append(keys, key)
+		}
+
+		if len(keys) == 0 {
+			return errors.New("Empty object in hashed value")
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			*components = append(*components, key)
+			if err := extractComponents(v[key], components); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("Unsupported type %T in hashed value", value)
+	}
+
+	return nil
+}
+
+// Converts a struct to its generic JSON form, keeping numbers exact
+func toJsonValue(value interface{}) (map[string]interface{}, error) {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(valueJson))
+	decoder.UseNumber()
+
+	var generic map[string]interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	return generic, nil
+}
+
+// SHA-256 of the source string
+func hashBuffer(value interface{}) ([]byte, error) {
+	source, err := sourceString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(source))
+	return hash[:], nil
+}
+
+// The 288 bit checksummed hash used for payload hashes and transaction ids, base58 encoded
+func chash288(value interface{}) (string, error) {
+	hash, err := hashBuffer(value)
+	if err != nil {
+		return "", err
+	}
+
+	checksumHash := sha256.Sum256(hash)
+	checksum := []byte{checksumHash[5], checksumHash[13], checksumHash[21], checksumHash[29]}
+
+	cleanBits := bytesToBits(hash)
+	checksumBits := bytesToBits(checksum)
+
+	mixed := make([]byte, 0, len(cleanBits)+len(checksumBits))
+	start := 0
+	for i, offset := range chash288Offsets {
+		end := offset - i
+		mixed = append(mixed, cleanBits[start:end]...)
+		mixed = append(mixed, checksumBits[i])
+		start = end
+	}
+	mixed = append(mixed, cleanBits[start:]...)
+
+	return base58Encode(bitsToBytes(mixed)), nil
+}
+
+func calcChashOffsets(length int) []int {
+	const pi = "14159265358979323846264338327950288419716939937510"
+
+	offsets := make([]int, 0, 32)
+	offset := 0
+	for _, digit := range pi {
+		relativeOffset := int(digit - '0')
+		if relativeOffset == 0 {
+			continue
+		}
+
+		offset += relativeOffset
+		if length == 288 {
+			offset += 4
+		}
+		if offset >= length {
+			break
+		}
+
+		offsets = append(offsets, offset)
+	}
+
+	return offsets
+}
+
+func bytesToBits(data []byte) []byte {
+	bits := make([]byte, 0, len(data)*8)
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			bits = append(bits, (b>>uint(i))&1)
+		}
+	}
+
+	return bits
+}
+
+func bitsToBytes(bits []byte) []byte {
+	data := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		data[i/8] |= bit << uint(7-i%8)
+	}
+
+	return data
+}
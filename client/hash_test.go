@@ -0,0 +1,146 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Vectors of the bitcoin base58 test suite, which uses the same alphabet
+func TestBase58(t *testing.T) {
+	tests := []struct {
+		hex     string
+		encoded string
+	}{
+		{"", ""},
+		{"61", "2g"},
+		{"626262", "a3gV"},
+		{"636363", "aPEr"},
+		{"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+		{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+		{"516b6fcd0f", "ABnLTmg"},
+		{"bf4f89001e670274dd", "3SEo3LWLoPntC"},
+		{"572e4794", "3EFU7m"},
+		{"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+		{"10c8511e", "Rt5zm"},
+		{"00000000000000000000", "1111111111"},
+	}
+
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.hex)
+		if encoded := base58Encode(data); encoded != test.encoded {
+			t.Errorf("base58Encode(%s) = %s, want %s", test.hex, encoded, test.encoded)
+		}
+
+		decoded, err := base58Decode(test.encoded)
+		if err != nil {
+			t.Errorf("base58Decode(%s): %s", test.encoded, err)
+			continue
+		}
+		if hex.EncodeToString(decoded) != test.hex {
+			t.Errorf("base58Decode(%s) = %x, want %s", test.encoded, decoded, test.hex)
+		}
+	}
+
+	if _, err := base58Decode("0OIl"); err == nil {
+		t.Error("Characters outside the alphabet accepted")
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	tests := []struct {
+		json   string
+		source []string
+	}{
+		{`{"a":"x"}`, []string{"a", "s", "x"}},
+		{`{"b":[1,"x"],"a":true}`, []string{"a", "b", "true", "b", "[", "n", "1", "s", "x", "]"}},
+		{`{"n":1.50,"skip":null,"empty":[]}`, []string{"n", "n", "1.50"}},
+		{`{"o":{"z":"1","y":"2"}}`, []string{"o", "y", "s", "2", "z", "s", "1"}},
+	}
+
+	for _, test := range tests {
+		decoder := json.NewDecoder(strings.NewReader(test.json))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+
+		source, err := sourceString(value)
+		if err != nil {
+			t.Errorf("sourceString(%s): %s", test.json, err)
+			continue
+		}
+		if want := strings.Join(test.source, "\x00"); source != want {
+			t.Errorf("sourceString(%s) = %q, want %q", test.json, source, want)
+		}
+	}
+
+	if _, err := sourceString(map[string]interface{}{"o": map[string]interface{}{}}); err == nil {
+		t.Error("Empty object accepted")
+	}
+}
+
+func TestChashOffsets(t *testing.T) {
+	if len(chash288Offsets) != 32 {
+		t.Fatalf("%d checksum offsets, want 32", len(chash288Offsets))
+	}
+
+	for i, offset := range chash288Offsets {
+		if offset >= 288 || (i > 0 && offset <= chash288Offsets[i-1]) {
+			t.Fatalf("Offsets are not increasing within 288 bits: %v", chash288Offsets)
+		}
+	}
+}
+
+// Takes the chash apart the way the node validates it: the bits at the offsets are the checksum of
+// the remaining 256 bits, which must be the SHA-256 of the source string.
+func TestChash288(t *testing.T) {
+	values := []map[string]interface{}{
+		{"a": "x"},
+		{"transaction_date": "2020-06-01T00:00:00.000Z", "version": "la0l", "shard_id": "AyAC3kjLtjM4vktAJ5Xq6mbXKjzEqXoSsmGhhgjnkXUvjtF2M"},
+	}
+
+	for _, value := range values {
+		chash, err := chash288(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := base58Decode(chash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != 36 {
+			t.Fatalf("chash %s decodes to %d bytes, want 36", chash, len(decoded))
+		}
+
+		bits := bytesToBits(decoded)
+		offsets := make(map[int]bool)
+		checksumBits := make([]byte, 0, 32)
+		for _, offset := range chash288Offsets {
+			offsets[offset] = true
+			checksumBits = append(checksumBits, bits[offset])
+		}
+		cleanBits := make([]byte, 0, 256)
+		for i, bit := range bits {
+			if !offsets[i] {
+				cleanBits = append(cleanBits, bit)
+			}
+		}
+
+		source, _ := sourceString(value)
+		hash := sha256.Sum256([]byte(source))
+		if clean := bitsToBytes(cleanBits); hex.EncodeToString(clean) != hex.EncodeToString(hash[:]) {
+			t.Errorf("chash %s does not carry the hash of its value", chash)
+		}
+
+		checksumHash := sha256.Sum256(hash[:])
+		checksum := []byte{checksumHash[5], checksumHash[13], checksumHash[21], checksumHash[29]}
+		if hex.EncodeToString(bitsToBytes(checksumBits)) != hex.EncodeToString(checksum) {
+			t.Errorf("chash %s has an invalid checksum", chash)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package client
+
+import "time"
+
+// Throttles the requests of a client. Wait blocks until a request of the operation may be sent and
+// returns how long it waited.
+type Limiter interface {
+	Wait(operation string) time.Duration
+}
@@ -0,0 +1,256 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	signRoute = "RVBqKlGdk9aEhi5J"
+	redacted  = "[redacted]"
+)
+
+type operationKey struct{}
+
+// A request to a node and its response, as written to a recording. The node signature, private
+// keys and the key map of sign requests are redacted.
+type Exchange struct {
+	Time         time.Time `json:"time"`
+	OffsetMs     float64   `json:"offset_ms"`
+	Operation    string    `json:"operation"`
+	NodeID       string    `json:"node_id"`
+	Method       string    `json:"method"`
+	RouteID      string    `json:"route_id"`
+	Query        string    `json:"query,omitempty"`
+	RequestBody  string    `json:"request_body,omitempty"`
+	Status       int       `json:"status,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	DurationMs   float64   `json:"duration_ms"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Writes the exchanges of any number of clients to a JSON lines file. The file is created with the
+// first exchange, so a recorder that is never used leaves no file behind.
+type Recorder struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	encoder *json.Encoder
+	start   time.Time
+	err     error
+}
+
+func NewRecorder(path string) *Recorder {
+	return &Recorder{
+		path:  path,
+		start: time.Now(),
+	}
+}
+
+// Closes the recording and returns the first error that prevented an exchange from being recorded
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil {
+		if err := r.file.Close(); err != nil && r.err == nil {
+			r.err = err
+		}
+		r.file = nil
+	}
+
+	return r.err
+}
+
+// Failed writes are remembered for Close, the recording must not fail the run
+func (r *Recorder) record(exchange *Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	if r.encoder == nil {
+		file, err := os.Create(r.path)
+		if err != nil {
+			r.err = errors.Wrap(err, "Failed to create recording")
+			return
+		}
+		r.file = file
+		r.encoder = json.NewEncoder(file)
+	}
+
+	exchange.OffsetMs = float64(exchange.Time.Sub(r.start)) / float64(time.Millisecond)
+	if err := r.encoder.Encode(exchange); err != nil {
+		r.err = errors.Wrap(err, "Failed to write recording")
+	}
+}
+
+// Reads the exchanges of a recording in the recorded order
+func ReadRecording(path string) ([]*Exchange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open recording")
+	}
+	defer file.Close()
+
+	exchanges := make([]*Exchange, 0)
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var exchange *Exchange
+		if err := decoder.Decode(&exchange); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to read exchange %d", len(exchanges)+1))
+		}
+		exchanges = append(exchanges, exchange)
+	}
+
+	return exchanges, nil
+}
+
+// Records every request that passes through the wrapped transport
+type recordingTransport struct {
+	next     http.RoundTripper
+	recorder *Recorder
+}
+
+func (t *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	exchange := &Exchange{
+		Time:   time.Now(),
+		Method: request.Method,
+		Query:  request.URL.RawQuery,
+	}
+	if operation, ok := request.Context().Value(operationKey{}).(string); ok {
+		exchange.Operation = operation
+	}
+
+	// /api/<node id>/<node signature>/<route id>
+	parts := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+	if len(parts) == 4 {
+		exchange.NodeID = parts[1]
+		exchange.RouteID = parts[3]
+	}
+
+	if request.Body != nil {
+		body, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		exchange.RequestBody = redactBody(exchange.RouteID, body)
+	}
+
+	resp, err := t.next.RoundTrip(request)
+	if err != nil {
+		exchange.DurationMs = sinceMs(exchange.Time)
+		exchange.Error = err.Error()
+		t.recorder.record(exchange)
+		return nil, err
+	}
+
+	// The body is read here so that the timing includes the transfer of the response
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	exchange.DurationMs = sinceMs(exchange.Time)
+	exchange.Status = resp.StatusCode
+	if err != nil {
+		exchange.Error = err.Error()
+		t.recorder.record(exchange)
+		return nil, err
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	exchange.ResponseBody = redactBody(exchange.RouteID, body)
+	t.recorder.record(exchange)
+
+	return resp, nil
+}
+
+// Redacts private keys anywhere in a JSON body and the key map of sign requests. Bodies that
+// are not JSON are kept as they are.
+func redactBody(routeID string, body []byte) string {
+	var content interface{}
+	if err := json.Unmarshal(body, &content); err != nil {
+		return string(body)
+	}
+
+	if object, ok := content.(map[string]interface{}); ok && routeID == signRoute {
+		if keyMap, ok := object["p1"].(map[string]interface{}); ok {
+			for address := range keyMap {
+				keyMap[address] = redacted
+			}
+		}
+	}
+
+	redactKeys(content)
+
+	redactedBody, err := json.Marshal(content)
+	if err != nil {
+		return ""
+	}
+
+	return string(redactedBody)
+}
+
+func redactKeys(content interface{}) {
+	switch value := content.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if key == "private_key_hex" {
+				value[key] = redacted
+				continue
+			}
+			redactKeys(field)
+		}
+	case []interface{}:
+		for _, item := range value {
+			redactKeys(item)
+		}
+	}
+}
+
+func sinceMs(start time.Time) float64 {
+	return float64(time.Since(start)) / float64(time.Millisecond)
+}
+
+// Records every request of the client. Must be called before the client is used.
+func (c *Client) SetRecorder(recorder *Recorder) {
+	c.httpClient.Transport = &recordingTransport{
+		next:     c.httpClient.Transport,
+		recorder: recorder,
+	}
+}
+
+// Sends a recorded request to the node of the client and returns its status. Redacted sign
+// requests are sent as recorded, so the node rejects them.
+func (c *Client) Replay(exchange *Exchange) (int, error) {
+	url := c.getBaseUrl() + "/" + exchange.RouteID
+	if exchange.Query != "" {
+		url += "?" + exchange.Query
+	}
+
+	request, err := http.NewRequest(exchange.Method, url, strings.NewReader(exchange.RequestBody))
+	if err != nil {
+		return 0, err
+	}
+	if exchange.RequestBody != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	status, _, err := c.send(exchange.Operation, request)
+	return status, err
+}
+
+func withOperation(request *http.Request, operation string) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), operationKey{}, operation))
+}
@@ -0,0 +1,214 @@
+package client
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/pkg/errors"
+	"sort"
+	"time"
+)
+
+// Turns an unsigned transaction into a signed one. The keyMap holds the private keys in hex and the
+// publicKeyMap the base58 public keys, both keyed by address base.
+// *Client implements it by asking the node to sign.
+type Signer interface {
+	SignTransaction(unsignedTx *UnsignedTransaction, keyMap map[string]string, publicKeyMap map[string]string) (*Transaction, error)
+}
+
+// Signs transactions in process, following the node's transaction hashing and signing scheme.
+// The parents of a locally signed transaction are the transactions of its inputs.
+type LocalSigner struct {
+	nodeID string
+	now    func() time.Time
+}
+
+func NewLocalSigner(nodeID string) *LocalSigner {
+	return &LocalSigner{
+		nodeID: nodeID,
+		now:    time.Now,
+	}
+}
+
+func (s *LocalSigner) SignTransaction(unsignedTx *UnsignedTransaction, keyMap map[string]string, publicKeyMap map[string]string) (*Transaction, error) {
+	if len(unsignedTx.InputList) == 0 {
+		return nil, errors.New("Transaction has no inputs")
+	}
+
+	inputs := make([]*TransactionInput, 0, len(unsignedTx.InputList))
+	parents := make(map[string]bool)
+	for i, input := range unsignedTx.InputList {
+		positioned := *input
+		positioned.InputPosition = uint(i)
+		inputs = append(inputs, &positioned)
+		parents[input.OutputTransactionID] = true
+	}
+
+	outputs := make([]*NewTransactionOutput, 0, len(unsignedTx.OutputList))
+	for i, output := range unsignedTx.OutputList {
+		outputs = append(outputs, &NewTransactionOutput{
+			OutputPosition:       uint(i),
+			AddressBase:          output.AddressBase,
+			AddressKeyIdentifier: output.AddressKeyIdentifier,
+			Amount:               output.Amount,
+			AddressVersion:       output.AddressVersion,
+		})
+	}
+
+	parentList := make([]string, 0, len(parents))
+	for parent := range parents {
+		parentList = append(parentList, parent)
+	}
+	sort.Strings(parentList)
+
+	signatureList := make([]map[string]interface{}, 0, len(publicKeyMap))
+	for addressBase, publicKey := range publicKeyMap {
+		signatureList = append(signatureList, map[string]interface{}{
+			"address_base":      addressBase,
+			"address_attribute": map[string]interface{}{"key_public": publicKey},
+		})
+	}
+
+	tx := &Transaction{
+		Inputs:          inputs,
+		Outputs:         outputs,
+		SignatureList:   signatureList,
+		ParentList:      parentList,
+		TransactionDate: s.now().UTC().Truncate(time.Second).Format("2006-01-02T15:04:05.000Z"),
+		ShardID:         unsignedTx.InputList[0].OutputShardID,
+		Version:         unsignedTx.TransactionVersion,
+		NodeIDOrigin:    s.nodeID,
+	}
+
+	return s.Resign(tx, keyMap)
+}
+
+// Recomputes the payload hash, the signatures and the transaction id of the transaction from its
+// other fields. Resigning a node-signed transaction must reproduce it exactly.
+func (s *LocalSigner) Resign(tx *Transaction, keyMap map[string]string) (*Transaction, error) {
+	signed := *tx
+	signed.PayloadHash = ""
+	signed.TransactionID = ""
+
+	signatureList := make([]map[string]interface{}, 0, len(tx.SignatureList))
+	for _, signature := range tx.SignatureList {
+		signatureList = append(signatureList, map[string]interface{}{
+			"address_base":      signature["address_base"],
+			"address_attribute": signature["address_attribute"],
+		})
+	}
+
+	sort.Slice(signatureList, func(x, y int) bool {
+		return fmt.Sprint(signatureList[x]["address_base"]) < fmt.Sprint(signatureList[y]["address_base"])
+	})
+
+	payload, err := transactionValue(&signed, false)
+	if err != nil {
+		return nil, err
+	}
+
+	signed.PayloadHash, err = chash288(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to hash payload")
+	}
+
+	signedValue, err := transactionValue(&signed, false)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := hashBuffer(signedValue)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to hash transaction")
+	}
+
+	for _, signature := range signatureList {
+		addressBase := fmt.Sprint(signature["address_base"])
+
+		privateKeyHex, ok := keyMap[addressBase]
+		if !ok {
+			return nil, fmt.Errorf("No private key for address %s", addressBase)
+		}
+
+		privateKey, err := hex.DecodeString(privateKeyHex)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid private key")
+		}
+
+		signature["signature"] = base58Encode(signECDSA(privateKey, message))
+	}
+
+	signed.SignatureList = signatureList
+
+	signed.TransactionID, err = TransactionID(&signed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signed, nil
+}
+
+// Computes the id of a signed transaction from all its other fields
+func TransactionID(tx *Transaction) (string, error) {
+	withSignatures, err := transactionValue(tx, true)
+	if err != nil {
+		return "", err
+	}
+
+	transactionID, err := chash288(withSignatures)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to hash transaction id")
+	}
+
+	return transactionID, nil
+}
+
+// The JSON form of the transaction without its id and, unless requested, without its signatures
+func transactionValue(tx *Transaction, withSignatures bool) (map[string]interface{}, error) {
+	value, err := toJsonValue(tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert transaction")
+	}
+
+	delete(value, "transaction_id")
+	if !withSignatures {
+		delete(value, "transaction_signature_list")
+	}
+	if tx.PayloadHash == "" {
+		delete(value, "payload_hash")
+	}
+
+	return value, nil
+}
+
+// Checks that the private key matches the base58 public key
+func VerifyKeyPair(privateKeyHex, publicKey string) error {
+	privateKey, err := hex.DecodeString(privateKeyHex)
+	if err != nil {
+		return errors.Wrap(err, "Invalid private key")
+	}
+
+	decoded, err := base58Decode(publicKey)
+	if err != nil {
+		return errors.Wrap(err, "Invalid public key")
+	}
+
+	if hex.EncodeToString(compressedPublicKey(privateKey)) != hex.EncodeToString(decoded) {
+		return errors.New("Private key does not match public key")
+	}
+
+	return nil
+}
+
+// Returns the 33 byte compressed public key of the private key
+func compressedPublicKey(privateKey []byte) []byte {
+	return secp256k1.PrivKeyFromBytes(privateKey).PubKey().SerializeCompressed()
+}
+
+// Signs the 32 byte hash with a deterministic RFC 6979 nonce and returns the 64 byte r || s
+// signature with a low s, the format produced by libsecp256k1
+func signECDSA(privateKey, hash []byte) []byte {
+	// The compact signature is the recovery code followed by r || s
+	return ecdsa.SignCompact(secp256k1.PrivKeyFromBytes(privateKey), hash, true)[1:]
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testPrivateKey = "0000000000000000000000000000000000000000000000000000000000000001"
+
+func testUnsignedTransaction(amount uint) *UnsignedTransaction {
+	return &UnsignedTransaction{
+		TransactionVersion: "la0l",
+		InputList: []*TransactionInput{{
+			AddressBase:           "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+			AddressKeyIdentifier:  "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+			AddressVersion:        "lal",
+			OutputPosition:        1,
+			OutputShardID:         "AyAC3kjLtjM4vktAJ5Xq6mbXKjzEqXoSsmGhhgjnkXUvjtF2M",
+			OutputTransactionDate: 1590969600,
+			OutputTransactionID:   "2Xuo4Fq4ZUgmhoomGKJWWycHBMrdQH5DSytiQYkrukdzDzaRk4",
+		}},
+		OutputList: []*TransactionOutput{{
+			AddressBase:          "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+			AddressVersion:       "lal",
+			AddressKeyIdentifier: "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+			Amount:               amount,
+		}},
+	}
+}
+
+func testSigner() *LocalSigner {
+	signer := NewLocalSigner("node")
+	signer.now = func() time.Time { return time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC) }
+	return signer
+}
+
+func TestLocalSignerSignsVerifiably(t *testing.T) {
+	publicKey := base58Encode(compressedPublicKey(big.NewInt(1).Bytes()))
+	keyMap := map[string]string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH": testPrivateKey}
+	publicKeyMap := map[string]string{"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH": publicKey}
+
+	tx, err := testSigner().SignTransaction(testUnsignedTransaction(10), keyMap, publicKeyMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned := *tx
+	unsigned.PayloadHash = ""
+	payload, _ := transactionValue(&unsigned, false)
+	if payloadHash, _ := chash288(payload); tx.PayloadHash != payloadHash {
+		t.Errorf("Payload hash %s, want %s", tx.PayloadHash, payloadHash)
+	}
+
+	signedValue, _ := transactionValue(tx, false)
+	message, _ := hashBuffer(signedValue)
+	signature, err := base58Decode(tx.SignatureList[0]["signature"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verifyECDSA(compressedPublicKey(big.NewInt(1).Bytes()), message, signature) {
+		t.Error("Signature does not verify against the public key")
+	}
+	signature[40] ^= 1
+	if verifyECDSA(compressedPublicKey(big.NewInt(1).Bytes()), message, signature) {
+		t.Error("Tampered signature verifies")
+	}
+
+	withSignatures, _ := transactionValue(tx, true)
+	if transactionID, _ := chash288(withSignatures); tx.TransactionID != transactionID {
+		t.Errorf("Transaction id %s, want %s", tx.TransactionID, transactionID)
+	}
+
+	resigned, err := testSigner().Resign(tx, keyMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resigned, tx) {
+		t.Error("Resigning a signed transaction changed it")
+	}
+
+	other, err := testSigner().SignTransaction(testUnsignedTransaction(11), keyMap, publicKeyMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.PayloadHash == tx.PayloadHash || other.TransactionID == tx.TransactionID {
+		t.Error("A different amount kept the payload hash or the transaction id")
+	}
+
+	if _, err := testSigner().Resign(tx, map[string]string{}); err == nil {
+		t.Error("Signed without the private key of the address")
+	}
+}
+
+// Transactions signed by a node and saved with `loader verify-signer -save`
+func TestResignNodeSignedTransactions(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "node_signed", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without them nothing checks the signer against the node, only against itself
+	if len(paths) == 0 {
+		t.Fatal("No node-signed transactions in testdata/node_signed, save some with `loader verify-signer -save`")
+	}
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fixture struct {
+			Transaction *Transaction      `json:"transaction"`
+			KeyMap      map[string]string `json:"key_map"`
+		}
+		if err := json.Unmarshal(content, &fixture); err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+
+		resigned, err := NewLocalSigner(fixture.Transaction.NodeIDOrigin).Resign(fixture.Transaction, fixture.KeyMap)
+		if err != nil {
+			t.Errorf("%s: %s", path, err)
+			continue
+		}
+
+		if resigned.PayloadHash != fixture.Transaction.PayloadHash {
+			t.Errorf("%s: payload hash %s, node %s", path, resigned.PayloadHash, fixture.Transaction.PayloadHash)
+		}
+		if !reflect.DeepEqual(resigned.SignatureList, fixture.Transaction.SignatureList) {
+			t.Errorf("%s: signatures %v, node %v", path, resigned.SignatureList, fixture.Transaction.SignatureList)
+		}
+		if resigned.TransactionID != fixture.Transaction.TransactionID {
+			t.Errorf("%s: transaction id %s, node %s", path, resigned.TransactionID, fixture.Transaction.TransactionID)
+		}
+	}
+}
+
+// Checks an r || s signature of the hash against a compressed public key
+func verifyECDSA(publicKey, hash, signature []byte) bool {
+	key, err := secp256k1.ParsePubKey(publicKey)
+	if err != nil {
+		return false
+	}
+
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) || r.IsZero() || s.IsZero() {
+		return false
+	}
+
+	return ecdsa.NewSignature(&r, &s).Verify(hash, key)
+}
+
+// Signatures of the widely used secp256k1 RFC 6979 vectors. The message is the SHA-256 of the
+// text and the signatures are the low-s r || s.
+var rfc6979Vectors = []struct {
+	key       string
+	message   string
+	signature string
+}{
+	{
+		key:       "0000000000000000000000000000000000000000000000000000000000000001",
+		message:   "Satoshi Nakamoto",
+		signature: "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d82442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+	},
+	{
+		key:       "0000000000000000000000000000000000000000000000000000000000000001",
+		message:   "All those moments will be lost in time, like tears in rain. Time to die...",
+		signature: "8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+	},
+	{
+		key:       "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140",
+		message:   "Satoshi Nakamoto",
+		signature: "fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d06b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5",
+	},
+	{
+		key:       "f8b8af8ce3c7cca5e300d33939540c10d45ce001b8f252bfbc57ba0342904181",
+		message:   "Alan Turing",
+		signature: "7063ae83e7f62bbb171798131b4a0564b956930092b33b07b395615d9ec7e15c58dfcc1e00a35e1572f366ffe34ba0fc47db1e7189759b9fb233c5b05ab388ea",
+	},
+}
+
+func TestSignECDSA(t *testing.T) {
+	for _, vector := range rfc6979Vectors {
+		key, _ := hex.DecodeString(vector.key)
+		hash := sha256.Sum256([]byte(vector.message))
+
+		signature := signECDSA(key, hash[:])
+		if hex.EncodeToString(signature) != vector.signature {
+			t.Errorf("Signature of %q with key %s = %x, want %s", vector.message, vector.key, signature, vector.signature)
+		}
+		if !verifyECDSA(compressedPublicKey(key), hash[:], signature) {
+			t.Errorf("Signature of %q does not verify", vector.message)
+		}
+	}
+}
+
+func TestCompressedPublicKey(t *testing.T) {
+	tests := []struct {
+		key       string
+		publicKey string
+	}{
+		{"01", "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{"02", "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"},
+		{"03", "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		if publicKey := hex.EncodeToString(compressedPublicKey(key)); publicKey != test.publicKey {
+			t.Errorf("compressedPublicKey(%s) = %s, want %s", test.key, publicKey, test.publicKey)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// The API operations of the node, used to pick the timeout of a request
+const (
+	OperationNodeID          = "node_id"
+	OperationOutputs         = "outputs"
+	OperationPrivateKey      = "private_key"
+	OperationSign            = "sign"
+	OperationSubmit          = "submit"
+	OperationAddressInfo     = "address_info"
+	OperationBalance         = "balance"
+	OperationGenerateAddress = "generate_address"
+)
+
+// Request timeouts per operation. Operations without their own timeout use Default and a zero
+// Default means no timeout.
+type Timeouts struct {
+	Default    time.Duration
+	Operations map[string]time.Duration
+}
+
+func (t *Timeouts) timeout(operation string) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	if timeout, ok := t.Operations[operation]; ok {
+		return timeout
+	}
+
+	return t.Default
+}
+
+// Returned when a request does not complete within the timeout of its operation
+type TimeoutError struct {
+	Operation string
+	Timeout   time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s request timed out after %v", e.Operation, e.Timeout)
+}
+
+// Reports whether the error, or the error it wraps, is a TimeoutError
+func IsTimeout(err error) (*TimeoutError, bool) {
+	timeoutErr, ok := errors.Cause(err).(*TimeoutError)
+	return timeoutErr, ok
+}
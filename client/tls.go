@@ -0,0 +1,107 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"strings"
+)
+
+// How a node's certificate is verified. Without a CA file the system roots are used. Pinned
+// certificates are given as hex SHA-256 fingerprints of their DER encoding, with or without colons.
+// Without a CA file the node's own certificate must be pinned and is trusted on its own, so
+// self-signed node certificates can be pinned. With a CA file any certificate of the verified chain
+// may be pinned. Insecure skips all verification and can not be combined with the other options.
+type TLSOptions struct {
+	CAFile       string
+	PinnedSHA256 []string
+	CertFile     string
+	KeyFile      string
+	ServerName   string
+	Insecure     bool
+}
+
+func NewTLSConfig(options *TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: options.ServerName,
+	}
+
+	if options.Insecure {
+		if options.CAFile != "" || len(options.PinnedSHA256) > 0 {
+			return nil, errors.New("Insecure mode can not be combined with a CA file or pinned certificates")
+		}
+
+		config.InsecureSkipVerify = true
+	}
+
+	if options.CAFile != "" {
+		caContent, err := ioutil.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to read CA file")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caContent) {
+			return nil, fmt.Errorf("No certificates found in CA file %s", options.CAFile)
+		}
+
+		config.RootCAs = pool
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to load client certificate")
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(options.PinnedSHA256) > 0 {
+		pins := make(map[string]bool)
+		for _, pin := range options.PinnedSHA256 {
+			fingerprint := strings.ToLower(strings.Replace(pin, ":", "", -1))
+			if decoded, err := hex.DecodeString(fingerprint); err != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("Invalid SHA-256 fingerprint %s", pin)
+			}
+			pins[fingerprint] = true
+		}
+
+		// Without a CA file the pin replaces the chain verification. The handshake only proves that
+		// the node holds the key of the leaf, so only the leaf is compared. With a CA file a pin may
+		// match any certificate of a verified chain.
+		if options.CAFile == "" {
+			config.InsecureSkipVerify = true
+			config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) > 0 && pinned(pins, rawCerts[0]) {
+					return nil
+				}
+
+				return errors.New("Node certificate does not match any pinned fingerprint")
+			}
+		} else {
+			config.VerifyPeerCertificate = func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+				for _, chain := range verifiedChains {
+					for _, cert := range chain {
+						if pinned(pins, cert.Raw) {
+							return nil
+						}
+					}
+				}
+
+				return errors.New("No verified node certificate matches a pinned fingerprint")
+			}
+		}
+	}
+
+	return config, nil
+}
+
+func pinned(pins map[string]bool, rawCert []byte) bool {
+	fingerprint := sha256.Sum256(rawCert)
+	return pins[hex.EncodeToString(fingerprint[:])]
+}
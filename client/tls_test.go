@@ -0,0 +1,128 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+func selfSignedCert(t *testing.T, name string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestPinnedLeafWithoutCA(t *testing.T) {
+	node := selfSignedCert(t, "node")
+	attacker := selfSignedCert(t, "attacker")
+
+	config, err := NewTLSConfig(&TLSOptions{PinnedSHA256: []string{fingerprint(node)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		chain [][]byte
+		valid bool
+	}{
+		{"pinned leaf", [][]byte{node}, true},
+		{"pinned leaf with extra certificate", [][]byte{node, attacker}, true},
+		{"other leaf followed by the pinned certificate", [][]byte{attacker, node}, false},
+		{"other leaf", [][]byte{attacker}, false},
+		{"no certificate", nil, false},
+	}
+
+	for _, test := range tests {
+		err := config.VerifyPeerCertificate(test.chain, nil)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: accepted", test.name)
+		}
+	}
+}
+
+func TestPinnedChainWithCA(t *testing.T) {
+	ca := selfSignedCert(t, "ca")
+	leaf := selfSignedCert(t, "leaf")
+
+	caCert, err := x509.ParseCertificate(ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafCert, err := x509.ParseCertificate(leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &TLSOptions{PinnedSHA256: []string{fingerprint(ca)}}
+	tlsConfig, err := NewTLSConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tlsConfig.InsecureSkipVerify {
+		t.Fatal("Pinning without a CA file must replace the chain verification")
+	}
+
+	config.CAFile = writeTempPEM(t, ca)
+	tlsConfig, err = NewTLSConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.InsecureSkipVerify {
+		t.Fatal("Pinning with a CA file must keep the chain verification")
+	}
+
+	if err := tlsConfig.VerifyPeerCertificate([][]byte{leaf, ca}, [][]*x509.Certificate{{leafCert, caCert}}); err != nil {
+		t.Errorf("Pinned CA in the verified chain rejected: %s", err)
+	}
+	if err := tlsConfig.VerifyPeerCertificate([][]byte{leaf, ca}, [][]*x509.Certificate{{leafCert}}); err == nil {
+		t.Error("Pinned certificate outside the verified chains accepted")
+	}
+}
+
+func writeTempPEM(t *testing.T, der []byte) string {
+	file, err := ioutil.TempFile("", "ca-*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	t.Cleanup(func() { os.Remove(file.Name()) })
+
+	if err := pem.Encode(file, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatal(err)
+	}
+
+	return file.Name()
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// Tuning of the HTTP transport. Zero values keep the net/http defaults and a nil TLS config
+// verifies the node against the system roots.
+type TransportConfig struct {
+	TLS                 *tls.Config
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	DisableKeepAlives   bool
+	HTTP2               bool
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+}
+
+// A round tripper that can be shared by all the clients of a node and counts how many requests
+// reused a connection
+type Transport struct {
+	// Accessed atomically, kept first for 64 bit alignment
+	requests uint64
+	reused   uint64
+	wasIdle  uint64
+
+	transport *http.Transport
+}
+
+type ConnectionStats struct {
+	Requests          uint64  `json:"request_count"`
+	NewConnections    uint64  `json:"new_connection_count"`
+	ReusedConnections uint64  `json:"reused_connection_count"`
+	IdleReused        uint64  `json:"idle_reused_count"`
+	ReuseRatio        float64 `json:"reuse_ratio"`
+}
+
+func NewTransport(config *TransportConfig) *Transport {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+
+	tlsConfig := config.TLS
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+
+	tr := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        config.MaxIdleConns,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.MaxConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		DisableKeepAlives:   config.DisableKeepAlives,
+		TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+		ForceAttemptHTTP2:   config.HTTP2,
+	}
+
+	if !config.HTTP2 {
+		// A non-nil empty map turns HTTP/2 off
+		tr.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return &Transport{transport: tr}
+}
+
+func (t *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.AddUint64(&t.requests, 1)
+			if info.Reused {
+				atomic.AddUint64(&t.reused, 1)
+			}
+			if info.WasIdle {
+				atomic.AddUint64(&t.wasIdle, 1)
+			}
+		},
+	}
+
+	return t.transport.RoundTrip(request.WithContext(httptrace.WithClientTrace(request.Context(), trace)))
+}
+
+func (t *Transport) Stats() *ConnectionStats {
+	stats := &ConnectionStats{
+		Requests:          atomic.LoadUint64(&t.requests),
+		ReusedConnections: atomic.LoadUint64(&t.reused),
+		IdleReused:        atomic.LoadUint64(&t.wasIdle),
+	}
+	stats.NewConnections = stats.Requests - stats.ReusedConnections
+
+	if stats.Requests > 0 {
+		stats.ReuseRatio = float64(stats.ReusedConnections) / float64(stats.Requests)
+	}
+
+	return stats
+}
+
+// Adds the counters of other, for example the stats of another process using the same node
+func (s *ConnectionStats) Add(other *ConnectionStats) {
+	s.Requests += other.Requests
+	s.NewConnections += other.NewConnections
+	s.ReusedConnections += other.ReusedConnections
+	s.IdleReused += other.IdleReused
+
+	s.ReuseRatio = 0
+	if s.Requests > 0 {
+		s.ReuseRatio = float64(s.ReusedConnections) / float64(s.Requests)
+	}
+}
+
+// Clears the counters, for example at the start of a timed window
+func (t *Transport) ResetStats() {
+	atomic.StoreUint64(&t.requests, 0)
+	atomic.StoreUint64(&t.reused, 0)
+	atomic.StoreUint64(&t.wasIdle, 0)
+}
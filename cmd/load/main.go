@@ -2,23 +2,65 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"millix-performance-test/load"
+	"millix-performance-test/report"
 	"os"
+	"strings"
+	"time"
 )
 
+// Exit code of a run that completed but failed its assertions. A panic exits with 2.
+const exitAssertionsFailed = 3
+
 func main() {
+	command := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+
+	switch command {
+	case "run":
+		run(args)
+	case "verify-signer":
+		verifySigner(args)
+	case "presign":
+		presign(args)
+	case "submit-corpus":
+		submitCorpus(args)
+	case "capacity":
+		capacity()
+	case "soak":
+		soak(args)
+	case "compare":
+		compare(args)
+	case "report":
+		writeReport(args)
+	case "replay":
+		replay(args)
+	case "coordinator":
+		coordinator(args)
+	case "worker":
+		worker(args)
+	default:
+		panic(fmt.Sprintf("Unknown command %s", command))
+	}
+}
+
+func readConfig() *load.LoadConfig {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
 		panic("Missing CONFIG_PATH")
 	}
 
-	resPath := os.Getenv("RESULT_PATH")
-	if resPath == "" {
-		resPath = "result.json"
-	}
+	return readConfigFile(configPath)
+}
 
+func readConfigFile(configPath string) *load.LoadConfig {
 	configFile, err := os.Open(configPath)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to open config: %s", err))
@@ -35,12 +77,103 @@ func main() {
 		panic(fmt.Sprintf("Failed to unmarshal config: %s", err))
 	}
 
+	if err := config.Validate(); err != nil {
+		panic(fmt.Sprintf("Invalid config: %s", err))
+	}
+
+	return config
+}
+
+func run(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	formats := formatFlag(flags)
+	flags.Parse(args)
+
+	config := readConfig()
+
 	orchestrator := load.NewOrchestrator(config)
+	defer closeOrchestrator(orchestrator)
 	loadRes, err := orchestrator.Load()
 	if err != nil {
 		panic(fmt.Sprintf("Orchestrator finished with error: %s\n", err))
 	}
 
+	writeLoadResult(loadRes, *formats)
+
+	if loadRes.Assertions != nil && !loadRes.Assertions.Passed {
+		os.Exit(exitAssertionsFailed)
+	}
+}
+
+// The additional result formats. An unknown format is rejected when the flags are parsed, so that it
+// does not waste a run.
+type formatsFlag []report.Writer
+
+func (f *formatsFlag) String() string {
+	return ""
+}
+
+func (f *formatsFlag) Set(format string) error {
+	writer, err := report.NewWriter(format)
+	if err != nil {
+		return err
+	}
+
+	*f = append(*f, writer)
+	return nil
+}
+
+func formatFlag(flags *flag.FlagSet) *formatsFlag {
+	formats := &formatsFlag{}
+	flags.Var(formats, "format", fmt.Sprintf("additional result format, one of %s, can be repeated", strings.Join(report.Formats(), ", ")))
+
+	return formats
+}
+
+// Writes a load result as JSON to RESULT_PATH, by default result.json, and in every additional format
+// next to it, with the .json of the path replaced by the extension of the format
+func writeLoadResult(loadRes *load.Result, formats []report.Writer) {
+	resPath := os.Getenv("RESULT_PATH")
+	if resPath == "" {
+		resPath = "result.json"
+	}
+
+	jsonWriter, _ := report.NewWriter(report.FormatJSON)
+	writeResultFile(resPath, jsonWriter, loadRes)
+
+	written := map[string]bool{jsonWriter.Extension(): true}
+	for _, writer := range formats {
+		if written[writer.Extension()] {
+			continue
+		}
+		written[writer.Extension()] = true
+
+		writeResultFile(fmt.Sprintf("%s.%s", strings.TrimSuffix(resPath, ".json"), writer.Extension()), writer, loadRes)
+	}
+
+	fmt.Printf("Done.\n")
+}
+
+func writeResultFile(resPath string, writer report.Writer, loadRes *load.Result) {
+	fmt.Printf("Writing result to %s.\n", resPath)
+
+	resFile, err := os.Create(resPath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create result file: %s", err))
+	}
+	defer resFile.Close()
+
+	if err := writer.Write(resFile, loadRes); err != nil {
+		panic(fmt.Sprintf("Failed to write result: %s", err))
+	}
+}
+
+func writeResult(loadRes interface{}) {
+	resPath := os.Getenv("RESULT_PATH")
+	if resPath == "" {
+		resPath = "result.json"
+	}
+
 	fmt.Printf("Writing result to %s.\n", resPath)
 
 	resFile, err := os.Create(resPath)
@@ -59,3 +192,231 @@ func main() {
 
 	fmt.Printf("Done.\n")
 }
+
+func verifySigner(args []string) {
+	flags := flag.NewFlagSet("verify-signer", flag.ExitOnError)
+	fixturePath := flags.String("save", "", "path to write the node-signed transaction and its key map to")
+	flags.Parse(args)
+
+	config := readConfig()
+
+	if err := load.VerifySigner(config, *fixturePath); err != nil {
+		panic(fmt.Sprintf("Signer verification failed: %s", err))
+	}
+}
+
+func presign(args []string) {
+	flags := flag.NewFlagSet("presign", flag.ExitOnError)
+	outPath := flags.String("out", "corpus.jsonl.gz", "path of the corpus to write")
+	flags.Parse(args)
+
+	config := readConfig()
+
+	orchestrator := load.NewOrchestrator(config)
+	defer closeOrchestrator(orchestrator)
+	if err := orchestrator.Presign(*outPath); err != nil {
+		panic(fmt.Sprintf("Failed to pre-sign corpus: %s", err))
+	}
+
+	fmt.Printf("Done.\n")
+}
+
+func submitCorpus(args []string) {
+	flags := flag.NewFlagSet("submit-corpus", flag.ExitOnError)
+	rate := flags.Float64("rate", 0, "total submissions per second, 0 submits at full speed")
+	formats := formatFlag(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		panic("Usage: loader submit-corpus [-rate tps] [-format csv] <corpus>")
+	}
+
+	config := readConfig()
+
+	orchestrator := load.NewOrchestrator(config)
+	defer closeOrchestrator(orchestrator)
+	loadRes, err := orchestrator.SubmitCorpus(flags.Arg(0), *rate)
+	if err != nil {
+		panic(fmt.Sprintf("Orchestrator finished with error: %s\n", err))
+	}
+
+	writeLoadResult(loadRes, *formats)
+}
+
+func capacity() {
+	config := readConfig()
+
+	orchestrator := load.NewOrchestrator(config)
+	defer closeOrchestrator(orchestrator)
+	capacityRes, err := orchestrator.Capacity()
+	if err != nil {
+		panic(fmt.Sprintf("Capacity search finished with error: %s\n", err))
+	}
+
+	writeResult(capacityRes)
+}
+
+func soak(args []string) {
+	flags := flag.NewFlagSet("soak", flag.ExitOnError)
+	formats := formatFlag(flags)
+	flags.Parse(args)
+
+	config := readConfig()
+
+	orchestrator := load.NewOrchestrator(config)
+	defer closeOrchestrator(orchestrator)
+	loadRes, err := orchestrator.Soak()
+	if err != nil {
+		panic(fmt.Sprintf("Soak finished with error: %s\n", err))
+	}
+
+	writeLoadResult(loadRes, *formats)
+}
+
+func compare(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	tpsTolerance := flags.Float64("tps-tolerance", 0.05, "allowed relative drop of the achieved TPS")
+	latencyTolerance := flags.Float64("latency-tolerance", 0.10, "allowed relative increase of a latency percentile")
+	errorRateTolerance := flags.Float64("error-rate-tolerance", 0.01, "allowed absolute increase of the error rate")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		panic("Usage: loader compare [flags] <baseline> <current>")
+	}
+
+	baseline, err := load.ReadResult(flags.Arg(0))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read baseline: %s", err))
+	}
+
+	current, err := load.ReadResult(flags.Arg(1))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read current result: %s", err))
+	}
+
+	comparison := load.CompareResults(baseline, current, &load.Tolerances{
+		Tps:       *tpsTolerance,
+		Latency:   *latencyTolerance,
+		ErrorRate: *errorRateTolerance,
+	})
+	comparison.Print()
+
+	if comparison.Regressions > 0 {
+		os.Exit(1)
+	}
+}
+
+func writeReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	html := flags.Bool("html", false, "write a static HTML report")
+	outPath := flags.String("out", "report.html", "path of the report to write")
+	configPath := flags.String("config", "", "config of the run, for results without metadata")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		panic("Usage: loader report -html [-out report.html] [-config config.json] <result>")
+	}
+
+	if !*html {
+		panic("No report format given, use -html")
+	}
+
+	loadRes, err := load.ReadResult(flags.Arg(0))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read result: %s", err))
+	}
+
+	// The config embedded in the result is already redacted
+	var config *load.LoadConfig
+	if loadRes.Metadata != nil {
+		config = loadRes.Metadata.Config
+	}
+	if *configPath != "" {
+		config, err = readConfigFile(*configPath).Redacted()
+		if err != nil {
+			panic(fmt.Sprintf("Failed to redact config: %s", err))
+		}
+	}
+
+	fmt.Printf("Writing report to %s.\n", *outPath)
+
+	reportFile, err := os.Create(*outPath)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create report file: %s", err))
+	}
+	defer reportFile.Close()
+
+	if err := report.WriteHTML(reportFile, loadRes, config); err != nil {
+		panic(fmt.Sprintf("Failed to write report: %s", err))
+	}
+
+	fmt.Printf("Done.\n")
+}
+
+// Reports recording errors without failing a finished run
+func closeOrchestrator(orchestrator *load.Orchestrator) {
+	if err := orchestrator.Close(); err != nil {
+		fmt.Printf("Failed to close orchestrator: %s\n", err)
+	}
+}
+
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	fast := flags.Bool("fast", false, "send the exchanges one after the other as fast as possible instead of with the original timing")
+	nodeID := flags.String("node", "", "id of the configured node that receives all exchanges, instead of the recorded nodes")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		panic("Usage: loader replay [-fast] [-node id] <recording>")
+	}
+
+	config := readConfig()
+
+	replayRes, err := load.Replay(config, flags.Arg(0), *nodeID, !*fast)
+	if err != nil {
+		panic(fmt.Sprintf("Replay finished with error: %s\n", err))
+	}
+
+	writeResult(replayRes)
+}
+
+func coordinator(args []string) {
+	flags := flag.NewFlagSet("coordinator", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:7000", "address of the control protocol")
+	workerCount := flags.Int("workers", 0, "number of workers to wait for")
+	timeout := flags.Duration("worker-timeout", 30*time.Minute, "time the workers have to become ready after the funding, and to report after the start")
+	formats := formatFlag(flags)
+	flags.Parse(args)
+
+	config := readConfig()
+
+	coordinator, err := load.NewCoordinator(config, *workerCount, os.Getenv("LOADER_TOKEN"), *timeout)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid coordinator setup: %s", err))
+	}
+
+	loadRes, err := coordinator.Run(*listen)
+	if err != nil {
+		panic(fmt.Sprintf("Coordinator finished with error: %s\n", err))
+	}
+
+	writeLoadResult(loadRes, *formats)
+
+	if loadRes.Assertions != nil && !loadRes.Assertions.Passed {
+		os.Exit(exitAssertionsFailed)
+	}
+}
+
+func worker(args []string) {
+	hostname, _ := os.Hostname()
+	flags := flag.NewFlagSet("worker", flag.ExitOnError)
+	coordinatorURL := flags.String("coordinator", "http://127.0.0.1:7000", "URL of the coordinator")
+	workerID := flags.String("id", fmt.Sprintf("%s-%d", hostname, os.Getpid()), "unique name of the worker")
+	flags.Parse(args)
+
+	if err := load.RunWorker(*coordinatorURL, *workerID, os.Getenv("LOADER_TOKEN")); err != nil {
+		panic(fmt.Sprintf("Worker finished with error: %s\n", err))
+	}
+
+	fmt.Printf("Done.\n")
+}
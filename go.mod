@@ -1,5 +1,8 @@
 module millix-performance-test
 
-go 1.14
+go 1.17
 
-require github.com/pkg/errors v0.9.1
+require (
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.1
+	github.com/pkg/errors v0.9.1
+)
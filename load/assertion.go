@@ -0,0 +1,89 @@
+package load
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+// Pass criteria of a run. Zero values are not checked, except MaxErrorRate, which is checked
+// whenever it is set so that a run can be required to have no failed transactions at all.
+type AssertionConfig struct {
+	MinTps                 float64  `json:"min_tps"`
+	MaxP99SubmitMs         float64  `json:"max_p99_submit_ms"`
+	MaxErrorRate           *float64 `json:"max_error_rate"`
+	MaxTimeToStableSeconds float64  `json:"max_time_to_stable_seconds"`
+}
+
+type AssertionResult struct {
+	Passed bool              `json:"passed"`
+	Checks []*AssertionCheck `json:"checks"`
+}
+
+type AssertionCheck struct {
+	Name   string  `json:"name"`
+	Limit  float64 `json:"limit"`
+	Actual float64 `json:"actual"`
+	Passed bool    `json:"passed"`
+}
+
+func (c *AssertionConfig) validate(config *LoadConfig) error {
+	if c.MinTps < 0 || c.MaxP99SubmitMs < 0 || c.MaxTimeToStableSeconds < 0 {
+		return errors.New("Assertion limits can not be negative")
+	}
+
+	if c.MaxErrorRate != nil && (*c.MaxErrorRate < 0 || *c.MaxErrorRate > 1) {
+		return errors.New("Assertion max_error_rate must be in [0, 1]")
+	}
+
+	if c.MaxTimeToStableSeconds > 0 && config.Verification == nil {
+		return errors.New("Assertion max_time_to_stable_seconds needs a verification block")
+	}
+
+	return nil
+}
+
+// Checks the result against the configured pass criteria
+func (c *AssertionConfig) evaluate(res *Result) *AssertionResult {
+	assertions := &AssertionResult{Passed: true, Checks: make([]*AssertionCheck, 0)}
+	check := func(name string, limit, actual float64, passed bool) {
+		assertions.Checks = append(assertions.Checks, &AssertionCheck{
+			Name:   name,
+			Limit:  limit,
+			Actual: actual,
+			Passed: passed,
+		})
+		if !passed {
+			assertions.Passed = false
+		}
+	}
+
+	if c.MinTps > 0 {
+		check("min_tps", c.MinTps, res.AchievedTps, res.AchievedTps >= c.MinTps)
+	}
+
+	// Only the submission is timed, so slow signing does not fail the check
+	if c.MaxP99SubmitMs > 0 {
+		for _, nodeResult := range res.Nodes {
+			if nodeResult.SubmitLatency == nil {
+				continue
+			}
+
+			p99 := nodeResult.SubmitLatency.P99Ms
+			check(fmt.Sprintf("max_p99_submit_ms %s", nodeResult.Address), c.MaxP99SubmitMs, p99, p99 <= c.MaxP99SubmitMs)
+		}
+	}
+
+	if c.MaxErrorRate != nil {
+		rate := errorRate(res.TotalTransactions, res.Failed)
+		check("max_error_rate", *c.MaxErrorRate, rate, rate <= *c.MaxErrorRate)
+	}
+
+	// A ledger that never became stable fails the check with the time it was given
+	if c.MaxTimeToStableSeconds > 0 && res.Verification != nil {
+		timeToStable := res.Verification.TimeToStableSeconds
+		check("max_time_to_stable_seconds", c.MaxTimeToStableSeconds, timeToStable,
+			res.Verification.Stable && timeToStable <= c.MaxTimeToStableSeconds)
+	}
+
+	return assertions
+}
@@ -0,0 +1,132 @@
+package load
+
+import (
+	"testing"
+)
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func TestAssertionEvaluate(t *testing.T) {
+	res := &Result{
+		TotalTransactions: 990,
+		Failed:            10,
+		AchievedTps:       100,
+		// The scenario latencies include the signing
+		Scenarios: map[string]*ScenarioResult{
+			ScenarioTransfer: {Count: 900, Latency: &LatencyStats{P99Ms: 500}},
+			ScenarioFanOut:   {Count: 90, Latency: &LatencyStats{P99Ms: 600}},
+		},
+		Nodes: []*NodeResult{
+			{Address: "a", SubmitLatency: &LatencyStats{P99Ms: 200}},
+			{Address: "b", SubmitLatency: &LatencyStats{P99Ms: 400}},
+		},
+		Verification: &VerificationResult{Stable: true, TimeToStableSeconds: 30},
+	}
+
+	tests := []struct {
+		name   string
+		config *AssertionConfig
+		checks map[string]bool
+		passed bool
+	}{
+		{
+			name:   "nothing configured",
+			config: &AssertionConfig{},
+			checks: map[string]bool{},
+			passed: true,
+		},
+		{
+			name:   "throughput at the limit",
+			config: &AssertionConfig{MinTps: 100},
+			checks: map[string]bool{"min_tps": true},
+			passed: true,
+		},
+		{
+			name:   "throughput below the limit",
+			config: &AssertionConfig{MinTps: 100.1},
+			checks: map[string]bool{"min_tps": false},
+		},
+		{
+			name:   "submit p99 checked per node",
+			config: &AssertionConfig{MaxP99SubmitMs: 300},
+			checks: map[string]bool{"max_p99_submit_ms a": true, "max_p99_submit_ms b": false},
+		},
+		{
+			name:   "nil error rate is not checked",
+			config: &AssertionConfig{MaxErrorRate: nil},
+			checks: map[string]bool{},
+			passed: true,
+		},
+		{
+			name:   "zero error rate is checked",
+			config: &AssertionConfig{MaxErrorRate: floatPointer(0)},
+			checks: map[string]bool{"max_error_rate": false},
+		},
+		{
+			name:   "error rate at the limit",
+			config: &AssertionConfig{MaxErrorRate: floatPointer(0.01)},
+			checks: map[string]bool{"max_error_rate": true},
+			passed: true,
+		},
+		{
+			name:   "time to stable",
+			config: &AssertionConfig{MaxTimeToStableSeconds: 20},
+			checks: map[string]bool{"max_time_to_stable_seconds": false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertions := test.config.evaluate(res)
+
+			if len(assertions.Checks) != len(test.checks) {
+				t.Fatalf("%d checks, want %d", len(assertions.Checks), len(test.checks))
+			}
+			for _, check := range assertions.Checks {
+				passed, ok := test.checks[check.Name]
+				if !ok {
+					t.Errorf("Unexpected check %s", check.Name)
+					continue
+				}
+				if check.Passed != passed {
+					t.Errorf("%s passed %v, want %v. Limit %g. Actual %g.", check.Name, check.Passed, passed, check.Limit, check.Actual)
+				}
+			}
+
+			if assertions.Passed != test.passed {
+				t.Errorf("Passed %v, want %v", assertions.Passed, test.passed)
+			}
+		})
+	}
+}
+
+func TestAssertionUnstableLedger(t *testing.T) {
+	res := &Result{Verification: &VerificationResult{Stable: false, TimeToStableSeconds: 10}}
+
+	assertions := (&AssertionConfig{MaxTimeToStableSeconds: 20}).evaluate(res)
+	if assertions.Passed {
+		t.Error("A ledger that never became stable passed")
+	}
+}
+
+func TestAssertionValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *AssertionConfig
+		valid  bool
+	}{
+		{"empty", &AssertionConfig{}, true},
+		{"negative tps", &AssertionConfig{MinTps: -1}, false},
+		{"zero error rate", &AssertionConfig{MaxErrorRate: floatPointer(0)}, true},
+		{"error rate above 1", &AssertionConfig{MaxErrorRate: floatPointer(1.5)}, false},
+		{"time to stable without verification", &AssertionConfig{MaxTimeToStableSeconds: 10}, false},
+	}
+
+	for _, test := range tests {
+		if err := test.config.validate(&LoadConfig{}); (err == nil) != test.valid {
+			t.Errorf("%s: validate returned %v", test.name, err)
+		}
+	}
+}
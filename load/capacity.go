@@ -0,0 +1,233 @@
+package load
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCapacityStepSeconds     = 30
+	defaultCapacityMinAchievedRate = 0.95
+	defaultCapacityMaxErrorRate    = 0.01
+)
+
+// Ramps the offered load from StartTps by StepTps every StepSeconds until a step breaches a threshold,
+// MaxTps is exceeded or the prepared outputs run out. A step breaches when the achieved TPS is below
+// MinAchievedRatio of the offered TPS, when more than MaxErrorRate of its transactions failed or when its
+// p99 latency is above MaxP99Ms. A MaxP99Ms or MaxTps of 0 is not checked.
+type CapacityConfig struct {
+	StartTps         float64 `json:"start_tps"`
+	StepTps          float64 `json:"step_tps"`
+	MaxTps           float64 `json:"max_tps"`
+	StepSeconds      uint    `json:"step_seconds"`
+	PauseSeconds     uint    `json:"pause_seconds"`
+	MinAchievedRatio float64 `json:"min_achieved_ratio"`
+	MaxErrorRate     float64 `json:"max_error_rate"`
+	MaxP99Ms         float64 `json:"max_p99_ms"`
+}
+
+type CapacityResult struct {
+	Metadata          *Metadata       `json:"metadata,omitempty"`
+	StartTime         *time.Time      `json:"start_time"`
+	EndTime           *time.Time      `json:"end_time"`
+	NodeCount         uint            `json:"node_count"`
+	MaxSustainableTps float64         `json:"max_sustainable_tps"`
+	MaxAchievedTps    float64         `json:"max_achieved_tps"`
+	StopReason        string          `json:"stop_reason"`
+	Steps             []*CapacityStep `json:"steps"`
+	Liveness          []*NodeLiveness `json:"liveness,omitempty"`
+}
+
+type CapacityStep struct {
+	OfferedTps        float64       `json:"offered_tps"`
+	AchievedTps       float64       `json:"achieved_tps"`
+	TotalTransactions uint          `json:"total_transaction_count"`
+	Failed            uint          `json:"failed_transaction_count"`
+	ErrorRate         float64       `json:"error_rate"`
+	Latency           *LatencyStats `json:"latency"`
+	Sustainable       bool          `json:"sustainable"`
+	Breaches          []string      `json:"breaches"`
+}
+
+func (c *CapacityConfig) validate() error {
+	if c.StartTps <= 0 || c.StepTps <= 0 {
+		return errors.New("Capacity start_tps and step_tps must be greater than 0")
+	}
+
+	if c.StepSeconds == 0 {
+		c.StepSeconds = defaultCapacityStepSeconds
+	}
+	if c.MinAchievedRatio == 0 {
+		c.MinAchievedRatio = defaultCapacityMinAchievedRate
+	}
+	if c.MaxErrorRate == 0 {
+		c.MaxErrorRate = defaultCapacityMaxErrorRate
+	}
+
+	return nil
+}
+
+// Funds the nodes, prepares their outputs once and runs the load steps from them
+func (o *Orchestrator) Capacity() (*CapacityResult, error) {
+	config := o.config.Capacity
+	if config == nil {
+		return nil, errors.New("No capacity block configured")
+	}
+
+	metadata, err := o.collectMetadata()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to collect run metadata")
+	}
+
+	o.startLiveness(phaseReceivers)
+	defer o.stopLiveness()
+
+	if err := o.prepareReceivers(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare receivers")
+	}
+
+	if err := o.ensureFunds(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare initial funds")
+	}
+
+	if err := o.prepareOutputs(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare transaction outputs")
+	}
+
+	// The keys and the worker clients are shared by all the steps
+	if err := o.prepareWorkers(); err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+	res := &CapacityResult{
+		Metadata:  metadata,
+		StartTime: &startTime,
+		NodeCount: uint(len(o.nodeConfigs)),
+		Steps:     make([]*CapacityStep, 0),
+	}
+
+	for offered := config.StartTps; config.MaxTps == 0 || offered <= config.MaxTps; offered += config.StepTps {
+		nodeTps := offered / float64(len(o.loadClients))
+		stepTransactionCount := uint(math.Ceil(nodeTps * float64(config.StepSeconds)))
+
+		fmt.Printf("[Orchestrator][Capacity] Step at %.1f tx/s. %d transactions per node.\n", offered, stepTransactionCount)
+
+		if !o.prepareStep(stepTransactionCount, nodeTps) {
+			res.StopReason = fmt.Sprintf("Prepared outputs ran out before the %.1f tx/s step", offered)
+			break
+		}
+
+		if o.config.Presign {
+			if err := o.presignTransactions(); err != nil {
+				return nil, errors.Wrap(err, "Failed to pre-sign transactions")
+			}
+		}
+
+		stepStart := time.Now()
+
+		nodeResults, err := o.sendTransactions()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to perform load step")
+		}
+
+		step := newCapacityStep(config, offered, o.summarise(stepStart, time.Now(), nodeResults), nodeResults)
+		res.Steps = append(res.Steps, step)
+
+		if step.AchievedTps > res.MaxAchievedTps {
+			res.MaxAchievedTps = step.AchievedTps
+		}
+
+		if !step.Sustainable {
+			res.StopReason = fmt.Sprintf("The %.1f tx/s step breached: %s", offered, strings.Join(step.Breaches, ", "))
+			break
+		}
+
+		res.MaxSustainableTps = offered
+
+		if config.PauseSeconds > 0 {
+			fmt.Printf("[Orchestrator][Capacity] Pausing for %d seconds.\n", config.PauseSeconds)
+			time.Sleep(time.Second * time.Duration(config.PauseSeconds))
+		}
+	}
+
+	if res.StopReason == "" {
+		res.StopReason = fmt.Sprintf("Reached max_tps %.1f", config.MaxTps)
+	}
+
+	endTime := time.Now()
+	res.EndTime = &endTime
+	res.Liveness = o.stopLiveness()
+
+	printCapacitySteps(res)
+
+	return res, nil
+}
+
+// Gives every load client the transactions of the next step. Returns false when a node does not have
+// enough prepared outputs left.
+func (o *Orchestrator) prepareStep(transactionCount uint, nodeTps float64) bool {
+	for _, loadClient := range o.loadClients {
+		loadClient.pending = loadClient.prepareTransactions(transactionCount)
+		loadClient.submitRate = nodeTps
+
+		if uint(len(loadClient.pending)) < transactionCount {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newCapacityStep(config *CapacityConfig, offered float64, res *Result, nodeResults []*NodeResult) *CapacityStep {
+	latencies := newLatencyRecorder()
+	for _, nodeResult := range nodeResults {
+		for _, recorder := range nodeResult.latencies {
+			latencies.merge(recorder)
+		}
+	}
+
+	step := &CapacityStep{
+		OfferedTps:        offered,
+		AchievedTps:       res.AchievedTps,
+		TotalTransactions: res.TotalTransactions,
+		Failed:            res.Failed,
+		Latency:           latencies.stats(),
+		Breaches:          make([]string, 0),
+	}
+
+	if attempted := res.TotalTransactions + res.Failed; attempted > 0 {
+		step.ErrorRate = float64(res.Failed) / float64(attempted)
+	}
+
+	if step.AchievedTps < offered*config.MinAchievedRatio {
+		step.Breaches = append(step.Breaches, fmt.Sprintf("achieved %.1f tx/s", step.AchievedTps))
+	}
+	if step.ErrorRate > config.MaxErrorRate {
+		step.Breaches = append(step.Breaches, fmt.Sprintf("error rate %.3f", step.ErrorRate))
+	}
+	if config.MaxP99Ms > 0 && step.Latency.P99Ms > config.MaxP99Ms {
+		step.Breaches = append(step.Breaches, fmt.Sprintf("p99 latency %.0f ms", step.Latency.P99Ms))
+	}
+
+	step.Sustainable = len(step.Breaches) == 0
+
+	return step
+}
+
+func printCapacitySteps(res *CapacityResult) {
+	fmt.Printf("[Orchestrator][Capacity] %12s %12s %8s %10s %10s  %s\n", "Offered tx/s", "Achieved", "Failed", "Error rate", "p99 ms", "Result")
+	for _, step := range res.Steps {
+		outcome := "OK"
+		if !step.Sustainable {
+			outcome = "BREACH: " + strings.Join(step.Breaches, ", ")
+		}
+
+		fmt.Printf("[Orchestrator][Capacity] %12.1f %12.1f %8d %10.3f %10.1f  %s\n", step.OfferedTps, step.AchievedTps, step.Failed, step.ErrorRate, step.Latency.P99Ms, outcome)
+	}
+
+	fmt.Printf("[Orchestrator][Capacity] Max sustainable: %.1f tx/s. %s.\n", res.MaxSustainableTps, res.StopReason)
+}
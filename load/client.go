@@ -3,6 +3,7 @@ package load
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	"math/rand"
 	"millix-performance-test/client"
 	"sort"
 	"sync"
@@ -11,38 +12,80 @@ import (
 )
 
 type LoadClient struct {
-	nodeIP                string
-	nodePort              string
-	nodeID                string
-	nodeSignature         string
-	addressBase           string
-	keyIdentifier         string
-	receiverAddressBase   string
-	receiverKeyIdentifier string
-	address               string
-	outputsPerTxCount     uint
-	goroutineCount        uint
-	keyMap                map[string]string
-	publicKeyMap          map[string]string
-	millixClient          *client.Client
-	preparedTransactions  []*client.Transaction
+	nodeIP               string
+	nodePort             string
+	nodeID               string
+	nodeSignature        string
+	addressBase          string
+	keyIdentifier        string
+	address              string
+	outputsPerTxCount    uint
+	outputAmount         uint
+	goroutineCount       uint
+	keyMap               map[string]string
+	publicKeyMap         map[string]string
+	millixClient         *client.Client
+	preparedTransactions []*client.Transaction
+	inputs               []*preparedInput
+	inputsMu             sync.Mutex
+	doubleSpendInputs    []*preparedInput
+	doubleSpendCount     uint
+	mutator              *mutator
+	propagation          *propagationProbe
+	localSigner          *client.LocalSigner
+	pending              []*pendingTransaction
+	workers              []*workerClients
+	signingRes           *SigningResult
+	submitRate           float64
+	signNodes            []*NodeConfig
+	submitNodes          []*NodeConfig
+	routed               bool
+	clients              *clientFactory
+	timeouts             *amountCounter
+	latencySampleLimit   int
+	intervals            *intervalRecorder
+	recycler             *recycler
+	soakUntil            time.Time
+	workload             *workload
+	receivers            receiverSelector
+	rng                  *rand.Rand
 }
 
-func NewLoadClient(millixClient *client.Client, nodeIP, nodePort, nodeID, nodeSignature, addressBase, keyIdentifier, receiverAddressBase, receiverKeyIdentifier string, outputsPerTxCount, goroutineCount uint) *LoadClient {
-	return &LoadClient{
-		nodeIP:                nodeIP,
-		nodePort:              nodePort,
-		nodeID:                nodeID,
-		nodeSignature:         nodeSignature,
-		addressBase:           addressBase,
-		keyIdentifier:         keyIdentifier,
-		receiverAddressBase:   receiverAddressBase,
-		receiverKeyIdentifier: receiverKeyIdentifier,
-		address:               fmt.Sprintf("%slal%s", addressBase, keyIdentifier),
-		millixClient:          millixClient,
-		outputsPerTxCount:     outputsPerTxCount,
-		goroutineCount:        goroutineCount,
+func NewLoadClient(millixClient *client.Client, clients *clientFactory, nodeConfig *NodeConfig, config *LoadConfig, rng *rand.Rand) *LoadClient {
+	lc := &LoadClient{
+		nodeIP:            nodeConfig.IP,
+		nodePort:          nodeConfig.Port,
+		nodeID:            nodeConfig.ID,
+		nodeSignature:     nodeConfig.Signature,
+		addressBase:       nodeConfig.AddressBase,
+		keyIdentifier:     nodeConfig.KeyIdentifier,
+		address:           fmt.Sprintf("%slal%s", nodeConfig.AddressBase, nodeConfig.KeyIdentifier),
+		millixClient:      millixClient,
+		outputsPerTxCount: config.OutputsPerTransaction,
+		outputAmount:      config.OutputAmount,
+		goroutineCount:    config.GoroutineCount,
+		workload:          newWorkload(config.WorkloadMix, rng),
+		rng:               rng,
+		clients:           clients,
+		timeouts:          newAmountCounter(),
 	}
+
+	lc.signNodes, lc.submitNodes = routedNodes(config, nodeConfig)
+	lc.routed = config.Routing != nil
+
+	if config.DoubleSpend != nil {
+		lc.doubleSpendCount = config.DoubleSpend.Count
+	}
+
+	if config.Signer == SignerLocal {
+		lc.localSigner = client.NewLocalSigner(nodeConfig.ID)
+	}
+
+	if config.Mutation != nil {
+		lc.mutator = newMutator(config.Mutation, lc.address, rng.Int63())
+	}
+
+	return lc
 }
 
 func (lc *LoadClient) Send(base, keyIdentifier string, amount uint) error {
@@ -55,30 +98,11 @@ func (lc *LoadClient) Balance() (uint, uint, error) {
 }
 
 func (lc *LoadClient) ObtainKeyMaps() error {
-	//if err := lc.millixClient.ObtainAddress(); err != nil {
-	//	return "", "", err
-	//}
-	//
-	//lc.keyIdentifier = lc.millixClient.GetKeyIdentifier()
-	//lc.address = lc.millixClient.GetAddress()
-
-	keyMap := make(map[string]string)
-	publicKeyMap := make(map[string]string)
-
-	privKey, err := lc.millixClient.GetPrivateKey(lc.address)
+	keyMap, publicKeyMap, err := loadKeyMaps(lc.millixClient, lc.address)
 	if err != nil {
 		return err
 	}
 
-	keyMap[lc.keyIdentifier] = privKey
-
-	info, err := lc.millixClient.GetAddressInfo(lc.address)
-	if err != nil {
-		return err
-	}
-
-	publicKeyMap[info.AddressBase] = info.AddressAttribute["key_public"]
-
 	lc.keyMap = keyMap
 	lc.publicKeyMap = publicKeyMap
 
@@ -114,7 +138,7 @@ func (lc *LoadClient) PrepareOutputs(totalOutputCount, outputPerTxCount uint) er
 	})
 
 	chosenOutput := outputs[0]
-	if chosenOutput.Amount < totalOutputCount {
+	if chosenOutput.Amount < totalOutputCount*lc.outputAmount {
 		return errors.New("Insufficient fund in the biggest output")
 	}
 
@@ -127,7 +151,7 @@ func (lc *LoadClient) PrepareOutputs(totalOutputCount, outputPerTxCount uint) er
 
 		receiverAmounts := make([]*client.ReceiverAmount, 0)
 		for j := uint(0); j < outputPerTxCount; j++ {
-			receiverAmounts = append(receiverAmounts, &client.ReceiverAmount{Amount: 1, AddressBase: lc.addressBase, KeyIdentifier: lc.keyIdentifier})
+			receiverAmounts = append(receiverAmounts, &client.ReceiverAmount{Amount: lc.outputAmount, AddressBase: lc.addressBase, KeyIdentifier: lc.keyIdentifier})
 		}
 
 		tx, err := lc.millixClient.SendMillixFromOutput(chosenOutput, receiverAmounts)
@@ -143,7 +167,7 @@ func (lc *LoadClient) PrepareOutputs(totalOutputCount, outputPerTxCount uint) er
 		transactions = append(transactions, tx)
 
 		chosenOutput = &client.TransactionOutput{
-			Amount:         chosenOutput.Amount - outputPerTxCount,
+			Amount:         chosenOutput.Amount - outputPerTxCount*lc.outputAmount,
 			TransactionID:  tx.TransactionID,
 			ShardID:        tx.ShardID,
 			AddressVersion: chosenOutput.AddressVersion,
@@ -161,12 +185,19 @@ func (lc *LoadClient) PrepareOutputs(totalOutputCount, outputPerTxCount uint) er
 	}
 
 	lc.preparedTransactions = transactions
+	lc.inputs = lc.preparedInputs()
+
+	if lc.doubleSpendCount > 0 {
+		lc.doubleSpendInputs = lc.takeInputs(lc.doubleSpendCount)
+		fmt.Printf("[Load Client] Reserved %d outputs for double spends.\n", len(lc.doubleSpendInputs))
+	}
 
 	return nil
 }
 
-func (lc *LoadClient) prepareTransactions() []*client.UnsignedTransaction {
-	unsignedTransactions := make([]*client.UnsignedTransaction, 0)
+// Lists the outputs created by the prepared transactions
+func (lc *LoadClient) preparedInputs() []*preparedInput {
+	inputs := make([]*preparedInput, 0)
 
 	for _, transaction := range lc.preparedTransactions {
 		// Starting from 1 to skip the change output
@@ -181,44 +212,302 @@ func (lc *LoadClient) prepareTransactions() []*client.UnsignedTransaction {
 				OutputTransactionID: transaction.TransactionID,
 			}
 
-			output := &client.TransactionOutput{
-				AddressBase:          lc.receiverAddressBase,
-				AddressVersion:       "lal",
-				AddressKeyIdentifier: lc.receiverKeyIdentifier,
-				Amount:               1,
-			}
+			inputs = append(inputs, &preparedInput{input: input, amount: lc.outputAmount})
+		}
+	}
+
+	return inputs
+}
+
+// Removes up to count inputs from the prepared inputs
+func (lc *LoadClient) takeInputs(count uint) []*preparedInput {
+	lc.inputsMu.Lock()
+	defer lc.inputsMu.Unlock()
+
+	if count > uint(len(lc.inputs)) {
+		count = uint(len(lc.inputs))
+	}
+
+	taken := lc.inputs[:count]
+	lc.inputs = lc.inputs[count:]
+
+	return taken
+}
+
+// Builds up to count load transactions from the prepared inputs
+func (lc *LoadClient) prepareTransactions(count uint) []*pendingTransaction {
+	pendingTransactions := make([]*pendingTransaction, 0)
+
+	for uint(len(pendingTransactions)) < count {
+		pendingTx := lc.nextTransaction()
+		if pendingTx == nil {
+			break
+		}
+
+		pendingTransactions = append(pendingTransactions, pendingTx)
+	}
+
+	fmt.Printf("[Load Client] Prepared %d unsigned transactions\n\n", len(pendingTransactions))
+
+	return pendingTransactions
+}
+
+// Builds the next load transaction from the prepared inputs. Returns nil when no inputs are left.
+func (lc *LoadClient) nextTransaction() *pendingTransaction {
+	lc.inputsMu.Lock()
+	defer lc.inputsMu.Unlock()
+
+	if len(lc.inputs) == 0 {
+		return nil
+	}
+
+	scenario := lc.workload.next()
 
-			unsignedTx := &client.UnsignedTransaction{
-				TransactionVersion: "la0l",
-				InputList:          []*client.TransactionInput{input},
-				OutputList:         []*client.TransactionOutput{output},
+	var unsignedTx *client.UnsignedTransaction
+	unsignedTx, lc.inputs = buildTransaction(scenario, lc.inputs, lc.receivers)
+
+	return &pendingTransaction{
+		scenario: scenario.Scenario,
+		unsigned: unsignedTx,
+	}
+}
+
+// The clients a single worker goroutine signs and submits with
+type workerClients struct {
+	sign       *client.Client
+	submit     *client.Client
+	submitNode *NodeConfig
+}
+
+// Creates the signing and the submitting clients of a worker goroutine. The workers are spread
+// evenly over the routed nodes. Transactions are signed with the keys of the loaded node's address,
+// so the clients need no address of their own.
+func (lc *LoadClient) newWorkerClients(id uint) *workerClients {
+	signNode := lc.signNodes[id%uint(len(lc.signNodes))]
+	submitNode := lc.submitNodes[id%uint(len(lc.submitNodes))]
+
+	signClient := lc.clients.client(signNode)
+	submitClient := signClient
+	if submitNode != signNode {
+		submitClient = lc.clients.client(submitNode)
+	}
+
+	return &workerClients{sign: signClient, submit: submitClient, submitNode: submitNode}
+}
+
+// Loads the signing keys and creates the clients of the worker goroutines, so that the timed window
+// only signs and submits. The keys are not loaded when every transaction is already signed.
+func (lc *LoadClient) PrepareWorkers() error {
+	if lc.needsKeys() {
+		if err := lc.ObtainKeyMaps(); err != nil {
+			return errors.Wrap(err, "Failed to load signing keys")
+		}
+	}
+
+	lc.workers = make([]*workerClients, 0, lc.goroutineCount)
+	for id := uint(0); id < lc.goroutineCount; id++ {
+		lc.workers = append(lc.workers, lc.newWorkerClients(id))
+	}
+
+	return nil
+}
+
+// Without pending transactions the load transactions are built and signed from the prepared inputs
+func (lc *LoadClient) needsKeys() bool {
+	if lc.pending == nil || len(lc.doubleSpendInputs) > 0 {
+		return true
+	}
+
+	for _, pendingTx := range lc.pending {
+		if pendingTx.signed == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (lc *LoadClient) signer(millixClient *client.Client) client.Signer {
+	if lc.localSigner != nil {
+		return lc.localSigner
+	}
+
+	return millixClient
+}
+
+// Signs the unsigned transaction, retrying up to 5 times. Returns nil when all attempts fail.
+func (lc *LoadClient) sign(id uint, signer client.Signer, unsignedTx *client.UnsignedTransaction) *client.Transaction {
+	for j := 0; j < 5; j++ {
+		tx, err := signer.SignTransaction(unsignedTx, lc.keyMap, lc.publicKeyMap)
+		if err != nil {
+			lc.countTimeout(err)
+			fmt.Printf("[Load Client] ID: %d. Attempt %d. Error: %s\n", id, j, err)
+			continue
+		}
+
+		return tx
+	}
+
+	fmt.Printf("[Load Client] ID: %d. Skipping transaction after 5 failed attempts.\n", id)
+	return nil
+}
+
+// Counts the error when it is a request timeout
+func (lc *LoadClient) countTimeout(err error) {
+	if timeoutErr, ok := client.IsTimeout(err); ok {
+		lc.timeouts.add(timeoutErr.Operation, 1)
+	}
+}
+
+// Signs all the pending transactions ahead of the timed window and drops the ones that failed to sign
+func (lc *LoadClient) presign(pendingTransactions []*pendingTransaction) ([]*pendingTransaction, *SigningResult) {
+	pendingTxChannel := make(chan *pendingTransaction, lc.goroutineCount)
+
+	go func() {
+		for _, pendingTx := range pendingTransactions {
+			pendingTxChannel <- pendingTx
+		}
+
+		close(pendingTxChannel)
+	}()
+
+	wg := sync.WaitGroup{}
+	wg.Add(int(lc.goroutineCount))
+
+	startTime := time.Now()
+	fmt.Printf("[Load Client] Pre-signing %d transactions.\n", len(pendingTransactions))
+
+	for i := uint(0); i < lc.goroutineCount; i++ {
+		go func(id uint) {
+			defer wg.Done()
+
+			signer := lc.signer(lc.workers[id].sign)
+			for pendingTx := range pendingTxChannel {
+				pendingTx.signed = lc.sign(id, signer, pendingTx.unsigned)
 			}
+		}(i)
+	}
+
+	wg.Wait()
 
-			unsignedTransactions = append(unsignedTransactions, unsignedTx)
+	duration := time.Since(startTime)
+
+	signed := make([]*pendingTransaction, 0, len(pendingTransactions))
+	for _, pendingTx := range pendingTransactions {
+		if pendingTx.signed != nil {
+			signed = append(signed, pendingTx)
 		}
 	}
 
-	fmt.Printf("[Load Client] Prepared %d unsigned transactions\n\n", len(unsignedTransactions))
+	res := &SigningResult{
+		TotalTransactions: uint(len(signed)),
+		Failed:            uint(len(pendingTransactions) - len(signed)),
+		DurationSeconds:   duration.Seconds(),
+		AchievedTps:       float64(len(signed)) / duration.Seconds(),
+	}
+
+	fmt.Printf("[Load Client] Pre-signed %d transactions in %v. Failed: %d. Tx/s: %f\n", res.TotalTransactions, duration, res.Failed, res.AchievedTps)
+
+	return signed, res
+}
+
+// Signs the pending transactions, or prepares and signs all the load transactions when none are pending,
+// so that SendTransactions only submits them. Fails when none of the transactions could be signed.
+func (lc *LoadClient) PresignTransactions() error {
+	pendingTransactions := lc.pending
+	if pendingTransactions == nil {
+		pendingTransactions = lc.prepareTransactions(uint(len(lc.inputs)))
+	}
+
+	lc.pending, lc.signingRes = lc.presign(pendingTransactions)
+	if len(lc.pending) == 0 && len(pendingTransactions) > 0 {
+		return fmt.Errorf("None of the %d transactions could be signed", len(pendingTransactions))
+	}
 
-	return unsignedTransactions
+	return nil
 }
 
-func (lc *LoadClient) SendTransactions() error {
-	unsignedTransactions := lc.prepareTransactions()
+func (lc *LoadClient) SendTransactions() (*NodeResult, error) {
+	if !lc.soakUntil.IsZero() {
+		return lc.soak(), nil
+	}
 
-	unsignedTxChannel := make(chan *client.UnsignedTransaction, lc.goroutineCount)
+	pendingTransactions := lc.pending
+	if pendingTransactions == nil {
+		pendingTransactions = lc.prepareTransactions(uint(len(lc.inputs)))
+	}
+	lc.pending = nil
+
+	// Corpus transactions may come from scenarios that are not in the current mix
+	scenarios := make([]string, 0, len(pendingTransactions))
+	for _, pendingTx := range pendingTransactions {
+		scenarios = append(scenarios, pendingTx.scenario)
+	}
+
+	position := 0
+	next := func() *pendingTransaction {
+		if position == len(pendingTransactions) {
+			return nil
+		}
+
+		pendingTx := pendingTransactions[position]
+		position++
+
+		return pendingTx
+	}
+
+	return lc.submitTransactions(scenarios, next), nil
+}
+
+// Runs the worker goroutines on the transactions returned by next until it returns nil.
+// Called from a single goroutine, next may block until a transaction is available.
+func (lc *LoadClient) submitTransactions(scenarios []string, next func() *pendingTransaction) *NodeResult {
+	pendingTxChannel := make(chan *pendingTransaction, lc.goroutineCount)
 
 	go func() {
-		for _, unsignedTransaction := range unsignedTransactions {
-			unsignedTxChannel <- unsignedTransaction
+		// Paces the workers when a submission rate is set
+		// A rate above one per nanosecond can not be paced and is sent at full speed
+		var ticker *time.Ticker
+		if lc.submitRate > 0 {
+			if interval := time.Duration(float64(time.Second) / lc.submitRate); interval > 0 {
+				ticker = time.NewTicker(interval)
+				defer ticker.Stop()
+			}
+		}
+
+		for pendingTx := next(); pendingTx != nil; pendingTx = next() {
+			if ticker != nil {
+				<-ticker.C
+			}
+			pendingTxChannel <- pendingTx
 		}
 
-		close(unsignedTxChannel)
+		close(pendingTxChannel)
 	}()
 
+	latencies := make(map[string]*latencyRecorder)
+	for _, scenario := range lc.workload.scenarios {
+		latencies[scenario.Scenario] = lc.newLatencyRecorder()
+	}
+	for _, scenario := range scenarios {
+		if _, ok := latencies[scenario]; !ok {
+			latencies[scenario] = lc.newLatencyRecorder()
+		}
+	}
+
+	// The submission alone, without the signing
+	submitLatencies := lc.newLatencyRecorder()
+
+	waits := map[string]*latencyRecorder{
+		RateSign:   lc.newLatencyRecorder(),
+		RateSubmit: lc.newLatencyRecorder(),
+	}
+
+	outbound := newAmountCounter()
+
 	wg := sync.WaitGroup{}
 	wg.Add(int(lc.goroutineCount))
-	var totalCount int32
+	var totalCount, failedCount int32
 
 	startTime := time.Now()
 	fmt.Printf("[Load Client] Starting. Time: %v\n", startTime)
@@ -234,35 +523,84 @@ func (lc *LoadClient) SendTransactions() error {
 
 			count := 0
 
-			millixClient := client.NewClient(lc.nodeIP, lc.nodePort, lc.nodeID, lc.nodeSignature, lc.addressBase, lc.keyIdentifier)
-			if err := millixClient.ObtainAddress(); err != nil {
-				fmt.Printf("[Load Client] Error: %s\n", err)
-				return
-			}
-
-			for unsignedTransaction := range unsignedTxChannel {
-				for j := 0; j < 5; j++ {
-					tx, err := millixClient.SignTransaction(unsignedTransaction, lc.keyMap, lc.publicKeyMap)
-					if err != nil {
-						fmt.Printf("[Load Client] ID: %d. Attempt %d. Error: %s\n", id, j, err)
-						if j == 5 {
-							fmt.Printf("[Load Client] ID: %d. Aborting", id)
-							return
-						}
-					} else {
-						if err := millixClient.SubmitTransaction(tx); err != nil {
-							fmt.Printf("[Load Client] Error: %s\n", err)
-							return
-						}
-
-						if count%100 == 0 {
-							fmt.Printf("[Load Client] ID: %d. Transaction %d. Hash: %s.\n", id, count, tx.TransactionID)
-						}
-						count++
-						atomic.AddInt32(&totalCount, 1)
-						break
+			signClient, submitClient, submitNode := lc.workers[id].sign, lc.workers[id].submit, lc.workers[id].submitNode
+			signer := lc.signer(signClient)
+
+			for pendingTx := range pendingTxChannel {
+				txStart := time.Now()
+				signWaitStart := signClient.LimiterWait()
+
+				// Pre-signed transactions only measure the submission
+				tx := pendingTx.signed
+				if tx == nil {
+					tx = lc.sign(id, signer, pendingTx.unsigned)
+					if tx == nil {
+						atomic.AddInt32(&failedCount, 1)
+						lc.intervals.fail()
+						continue
 					}
 				}
+
+				// Time spent waiting for the rate limiters is not part of the latency
+				signWait := signClient.LimiterWait() - signWaitStart
+				signDuration := time.Since(txStart) - signWait
+
+				mutation := ""
+				if lc.mutator != nil {
+					mutation = lc.mutator.next()
+				}
+
+				// A corrupted transaction is submitted instead of the valid one. It spends inputs that
+				// no other transaction spends, so the node can only refuse it for the corruption.
+				if mutation != "" && mutation != MutationDuplicate {
+					mutated := mutateTransaction(mutation, tx)
+					lc.mutator.record(mutation, mutated, submitClient.SubmitTransaction(mutated))
+					continue
+				}
+
+				submitStart := time.Now()
+				submitWaitStart := submitClient.LimiterWait()
+				err := submitClient.SubmitTransaction(tx)
+				submitWait := submitClient.LimiterWait() - submitWaitStart
+
+				if pendingTx.signed == nil && lc.localSigner == nil {
+					waits[RateSign].record(signWait)
+				}
+				waits[RateSubmit].record(submitWait)
+
+				if err != nil {
+					// A failed submission does not stop the worker, a timed out one may still be accepted
+					lc.countTimeout(err)
+					atomic.AddInt32(&failedCount, 1)
+					lc.intervals.fail()
+					fmt.Printf("[Load Client] ID: %d. Error: %s\n", id, err)
+					continue
+				}
+
+				submitDuration := time.Since(submitStart) - submitWait
+				submitLatencies.record(submitDuration)
+
+				latency := signDuration + submitDuration
+				latencies[pendingTx.scenario].record(latency)
+				lc.intervals.record(latency)
+
+				if lc.propagation != nil {
+					lc.propagation.observe(nodeAddress(submitNode), tx.TransactionID, pendingTx.unsigned.OutputList[0].AddressKeyIdentifier, submitStart.Add(submitWait))
+				}
+
+				// The duplicate follows the accepted valid transaction. Its submission is not part of the latency.
+				if mutation == MutationDuplicate {
+					lc.mutator.record(mutation, tx, submitClient.SubmitTransaction(tx))
+				}
+				for _, output := range pendingTx.unsigned.OutputList {
+					outbound.add(fmt.Sprintf("%slal%s", output.AddressBase, output.AddressKeyIdentifier), output.Amount)
+				}
+
+				if count%100 == 0 {
+					fmt.Printf("[Load Client] ID: %d. Transaction %d. Hash: %s.\n", id, count, tx.TransactionID)
+				}
+				count++
+				atomic.AddInt32(&totalCount, 1)
 			}
 		}(i)
 	}
@@ -275,5 +613,42 @@ func (lc *LoadClient) SendTransactions() error {
 	diff := endTime.Sub(startTime)
 	fmt.Printf("[Load Client] Total duration: %v. Seconds: %f. Tx/s: %f\n", diff, diff.Seconds(), float64(totalCount)/diff.Seconds())
 
-	return nil
+	res := &NodeResult{
+		Address:           lc.address,
+		TotalTransactions: uint(totalCount),
+		Failed:            uint(failedCount),
+		Timeouts:          lc.timeouts.snapshot(),
+		AchievedTps:       float64(totalCount) / diff.Seconds(),
+		Scenarios:         scenarioResults(latencies),
+		SubmitLatency:     submitLatencies.stats(),
+		Outbound:          outbound.total(),
+		OutboundByAddress: outbound.snapshot(),
+		Signing:           lc.signingRes,
+		latencies:         latencies,
+	}
+
+	if lc.clients.limits.limited() {
+		res.RateLimitWait = waitResults(waits)
+		res.waits = waits
+	}
+
+	if lc.routed {
+		res.SignNodes = nodeIDs(lc.signNodes)
+		res.SubmitNodes = nodeIDs(lc.submitNodes)
+	}
+
+	if lc.mutator != nil {
+		res.Mutations = lc.mutator.result()
+	}
+
+	return res
+}
+
+// Long runs keep a bounded sample of the latencies
+func (lc *LoadClient) newLatencyRecorder() *latencyRecorder {
+	if lc.latencySampleLimit > 0 {
+		return newSampledLatencyRecorder(lc.latencySampleLimit)
+	}
+
+	return newLatencyRecorder()
 }
@@ -0,0 +1,220 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"sort"
+)
+
+const (
+	ComparisonOk          = "ok"
+	ComparisonRegression  = "regression"
+	ComparisonImprovement = "improvement"
+	// The metric is only present in one of the results
+	ComparisonMissing = "missing"
+)
+
+// Allowed changes before a metric counts as a regression or an improvement. Tps and Latency are
+// relative changes, ErrorRate is an absolute change of the failed share of the transactions.
+type Tolerances struct {
+	Tps       float64
+	Latency   float64
+	ErrorRate float64
+}
+
+type Comparison struct {
+	Metrics      []*MetricComparison `json:"metrics"`
+	Regressions  uint                `json:"regression_count"`
+	Improvements uint                `json:"improvement_count"`
+}
+
+type MetricComparison struct {
+	Name     string  `json:"name"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Change   float64 `json:"change"`
+	Status   string  `json:"status"`
+}
+
+func ReadResult(path string) (*Result, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to read result %s", path))
+	}
+
+	var res *Result
+	if err := json.Unmarshal(content, &res); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("Failed to unmarshal result %s", path))
+	}
+
+	return res, nil
+}
+
+// Compares the throughput, error rates and latency percentiles of two results, in total and per node
+func CompareResults(baseline, current *Result, tolerances *Tolerances) *Comparison {
+	c := &Comparison{Metrics: make([]*MetricComparison, 0)}
+
+	c.higherIsBetter("achieved_tps", baseline.AchievedTps, current.AchievedTps, tolerances.Tps)
+	c.errorRate("error_rate", errorRate(baseline.TotalTransactions, baseline.Failed), errorRate(current.TotalTransactions, current.Failed), tolerances.ErrorRate)
+	c.scenarios("", baseline.Scenarios, current.Scenarios, tolerances, true)
+
+	baselineNodes := make(map[string]*NodeResult)
+	for _, node := range baseline.Nodes {
+		baselineNodes[node.Address] = node
+	}
+	currentNodes := make(map[string]*NodeResult)
+	for _, node := range current.Nodes {
+		currentNodes[node.Address] = node
+	}
+
+	for _, address := range unionKeys(baselineNodes, currentNodes) {
+		prefix := fmt.Sprintf("node %s ", address)
+		baselineNode, inBaseline := baselineNodes[address]
+		currentNode, inCurrent := currentNodes[address]
+		// A node that is gone from the current run lost all its throughput, a new node is only reported
+		if !inCurrent {
+			c.add(prefix+"achieved_tps", baselineNode.AchievedTps, 0, -1, true, false)
+			continue
+		}
+		if !inBaseline {
+			c.missing(prefix + "achieved_tps")
+			continue
+		}
+
+		c.higherIsBetter(prefix+"achieved_tps", baselineNode.AchievedTps, currentNode.AchievedTps, tolerances.Tps)
+		c.errorRate(prefix+"error_rate", errorRate(baselineNode.TotalTransactions, baselineNode.Failed), errorRate(currentNode.TotalTransactions, currentNode.Failed), tolerances.ErrorRate)
+		c.scenarios(prefix, baselineNode.Scenarios, currentNode.Scenarios, tolerances, false)
+	}
+
+	for _, metric := range c.Metrics {
+		switch metric.Status {
+		case ComparisonRegression:
+			c.Regressions++
+		case ComparisonImprovement:
+			c.Improvements++
+		}
+	}
+
+	return c
+}
+
+// Compares the p50, p90 and p99 latencies of every scenario, or only the p99 for the per node metrics
+func (c *Comparison) scenarios(prefix string, baseline, current map[string]*ScenarioResult, tolerances *Tolerances, allPercentiles bool) {
+	for _, scenario := range unionScenarios(baseline, current) {
+		baselineScenario, inBaseline := baseline[scenario]
+		currentScenario, inCurrent := current[scenario]
+		name := fmt.Sprintf("%s%s p99_ms", prefix, scenario)
+		if !inBaseline || !inCurrent || baselineScenario.Latency == nil || currentScenario.Latency == nil {
+			c.missing(name)
+			continue
+		}
+
+		if allPercentiles {
+			c.lowerIsBetter(fmt.Sprintf("%s%s p50_ms", prefix, scenario), baselineScenario.Latency.P50Ms, currentScenario.Latency.P50Ms, tolerances.Latency)
+			c.lowerIsBetter(fmt.Sprintf("%s%s p90_ms", prefix, scenario), baselineScenario.Latency.P90Ms, currentScenario.Latency.P90Ms, tolerances.Latency)
+		}
+		c.lowerIsBetter(name, baselineScenario.Latency.P99Ms, currentScenario.Latency.P99Ms, tolerances.Latency)
+	}
+}
+
+func (c *Comparison) higherIsBetter(name string, baseline, current, tolerance float64) {
+	change := relativeChange(baseline, current)
+	c.add(name, baseline, current, change, change < -tolerance, change > tolerance)
+}
+
+func (c *Comparison) lowerIsBetter(name string, baseline, current, tolerance float64) {
+	change := relativeChange(baseline, current)
+	c.add(name, baseline, current, change, change > tolerance, change < -tolerance)
+}
+
+func (c *Comparison) errorRate(name string, baseline, current, tolerance float64) {
+	change := current - baseline
+	c.add(name, baseline, current, change, change > tolerance, change < -tolerance)
+}
+
+func (c *Comparison) add(name string, baseline, current, change float64, regression, improvement bool) {
+	status := ComparisonOk
+	if regression {
+		status = ComparisonRegression
+	} else if improvement {
+		status = ComparisonImprovement
+	}
+
+	c.Metrics = append(c.Metrics, &MetricComparison{
+		Name:     name,
+		Baseline: baseline,
+		Current:  current,
+		Change:   change,
+		Status:   status,
+	})
+}
+
+func (c *Comparison) missing(name string) {
+	c.Metrics = append(c.Metrics, &MetricComparison{Name: name, Status: ComparisonMissing})
+}
+
+// A metric that grows from 0 counts as a 100% change
+func relativeChange(baseline, current float64) float64 {
+	if baseline == 0 {
+		if current == 0 {
+			return 0
+		}
+		return 1
+	}
+
+	return (current - baseline) / baseline
+}
+
+func unionKeys(baseline, current map[string]*NodeResult) []string {
+	keys := make([]string, 0)
+	for key := range baseline {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := baseline[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func unionScenarios(baseline, current map[string]*ScenarioResult) []string {
+	keys := make([]string, 0)
+	for key := range baseline {
+		keys = append(keys, key)
+	}
+	for key := range current {
+		if _, ok := baseline[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Comparison) Print() {
+	width := len("Metric")
+	for _, metric := range c.Metrics {
+		if len(metric.Name) > width {
+			width = len(metric.Name)
+		}
+	}
+
+	fmt.Printf("%-*s %14s %14s %9s  %s\n", width, "Metric", "Baseline", "Current", "Change", "Status")
+	for _, metric := range c.Metrics {
+		if metric.Status == ComparisonMissing {
+			fmt.Printf("%-*s %14s %14s %9s  %s\n", width, metric.Name, "-", "-", "-", metric.Status)
+			continue
+		}
+
+		change := fmt.Sprintf("%+.1f%%", metric.Change*100)
+		fmt.Printf("%-*s %14.3f %14.3f %9s  %s\n", width, metric.Name, metric.Baseline, metric.Current, change, metric.Status)
+	}
+
+	fmt.Printf("\n%d regressions. %d improvements.\n", c.Regressions, c.Improvements)
+}
@@ -0,0 +1,131 @@
+package load
+
+import (
+	"testing"
+)
+
+var testTolerances = &Tolerances{Tps: 0.05, Latency: 0.10, ErrorRate: 0.01}
+
+func testNode(address string, tps float64, sent, failed uint, p99 float64) *NodeResult {
+	return &NodeResult{
+		Address:           address,
+		TotalTransactions: sent,
+		Failed:            failed,
+		AchievedTps:       tps,
+		Scenarios: map[string]*ScenarioResult{
+			ScenarioTransfer: {Count: sent, Latency: &LatencyStats{P50Ms: p99 / 2, P90Ms: p99 * 0.9, P99Ms: p99}},
+		},
+	}
+}
+
+// A result made of the given nodes, with their totals and the latencies of the first node
+func testResult(nodes ...*NodeResult) *Result {
+	res := &Result{Nodes: nodes, Scenarios: nodes[0].Scenarios}
+	for _, node := range nodes {
+		res.TotalTransactions += node.TotalTransactions
+		res.Failed += node.Failed
+		res.AchievedTps += node.AchievedTps
+	}
+
+	return res
+}
+
+func TestCompareResults(t *testing.T) {
+	baseline := testResult(testNode("a", 100, 1000, 0, 100), testNode("b", 100, 1000, 0, 100))
+
+	tests := []struct {
+		name         string
+		current      *Result
+		statuses     map[string]string
+		regressions  uint
+		improvements uint
+	}{
+		{
+			name:     "unchanged",
+			current:  testResult(testNode("a", 100, 1000, 0, 100), testNode("b", 100, 1000, 0, 100)),
+			statuses: map[string]string{"achieved_tps": ComparisonOk, "transfer p99_ms": ComparisonOk, "node a achieved_tps": ComparisonOk},
+		},
+		{
+			name:     "throughput drop within tolerance",
+			current:  testResult(testNode("a", 96, 1000, 0, 100), testNode("b", 96, 1000, 0, 100)),
+			statuses: map[string]string{"achieved_tps": ComparisonOk, "node a achieved_tps": ComparisonOk},
+		},
+		{
+			name:        "throughput drop just past tolerance",
+			current:     testResult(testNode("a", 94.9, 1000, 0, 100), testNode("b", 100, 1000, 0, 100)),
+			statuses:    map[string]string{"achieved_tps": ComparisonOk, "node a achieved_tps": ComparisonRegression, "node b achieved_tps": ComparisonOk},
+			regressions: 1,
+		},
+		{
+			name:        "latency increase just past tolerance",
+			current:     testResult(testNode("a", 100, 1000, 0, 111), testNode("b", 100, 1000, 0, 100)),
+			statuses:    map[string]string{"transfer p50_ms": ComparisonRegression, "transfer p99_ms": ComparisonRegression, "node a transfer p99_ms": ComparisonRegression},
+			regressions: 4,
+		},
+		{
+			name:        "error rate increase just past tolerance",
+			current:     testResult(testNode("a", 100, 980, 20, 100), testNode("b", 100, 1000, 0, 100)),
+			statuses:    map[string]string{"error_rate": ComparisonOk, "node a error_rate": ComparisonRegression},
+			regressions: 1,
+		},
+		{
+			name:         "throughput improvement",
+			current:      testResult(testNode("a", 110, 1000, 0, 100), testNode("b", 110, 1000, 0, 100)),
+			statuses:     map[string]string{"achieved_tps": ComparisonImprovement, "node b achieved_tps": ComparisonImprovement},
+			improvements: 3,
+		},
+		{
+			name:     "missing node",
+			current:  testResult(testNode("a", 100, 1000, 0, 100)),
+			statuses: map[string]string{"node a achieved_tps": ComparisonOk, "node b achieved_tps": ComparisonRegression},
+			// Half the throughput of the run is gone with the node
+			regressions: 2,
+		},
+		{
+			name:     "new node",
+			current:  testResult(testNode("a", 100, 1000, 0, 100), testNode("b", 100, 1000, 0, 100), testNode("c", 100, 1000, 0, 100)),
+			statuses: map[string]string{"node c achieved_tps": ComparisonMissing},
+			// The node adds to the total throughput
+			improvements: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			comparison := CompareResults(baseline, test.current, testTolerances)
+
+			statuses := make(map[string]string)
+			for _, metric := range comparison.Metrics {
+				statuses[metric.Name] = metric.Status
+			}
+			for name, status := range test.statuses {
+				if statuses[name] != status {
+					t.Errorf("%s is %q, want %q", name, statuses[name], status)
+				}
+			}
+
+			if comparison.Regressions != test.regressions || comparison.Improvements != test.improvements {
+				t.Errorf("%d regressions and %d improvements, want %d and %d", comparison.Regressions, comparison.Improvements, test.regressions, test.improvements)
+			}
+		})
+	}
+}
+
+func TestRelativeChange(t *testing.T) {
+	tests := []struct {
+		baseline float64
+		current  float64
+		change   float64
+	}{
+		{100, 110, 0.1},
+		{100, 50, -0.5},
+		{0, 0, 0},
+		{0, 5, 1},
+	}
+
+	for _, test := range tests {
+		if change := relativeChange(test.baseline, test.current); change != test.change {
+			t.Errorf("relativeChange(%g, %g) = %g, want %g", test.baseline, test.current, change, test.change)
+		}
+	}
+}
@@ -1,19 +1,342 @@
 package load
 
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const redacted = "[redacted]"
+
 type LoadConfig struct {
-	NodeConfigs           []*NodeConfig `json:"nodes"`
-	TransactionPerNode    uint          `json:"transactions_per_node"`
-	OutputsPerTransaction uint          `json:"outputs_per_transaction"`
-	GoroutineCount        uint          `json:"goroutine_count"`
-	ReceiverAddressBase   string        `json:"receiver_address_base"`
-	ReceiverKeyIdentifier string        `json:"receiver_key_identifier"`
+	NodeConfigs           []*NodeConfig       `json:"nodes"`
+	TransactionPerNode    uint                `json:"transactions_per_node"`
+	OutputsPerTransaction uint                `json:"outputs_per_transaction"`
+	OutputAmount          uint                `json:"output_amount"`
+	GoroutineCount        uint                `json:"goroutine_count"`
+	ReceiverAddressBase   string              `json:"receiver_address_base"`
+	ReceiverKeyIdentifier string              `json:"receiver_key_identifier"`
+	Receivers             []*ReceiverConfig   `json:"receivers"`
+	GeneratedReceivers    uint                `json:"generated_receiver_count"`
+	ReceiverGeneratorNode uint                `json:"receiver_generator_node"`
+	ReceiverStrategy      string              `json:"receiver_strategy"`
+	ZipfExponent          float64             `json:"zipf_exponent"`
+	Topology              string              `json:"topology"`
+	WorkloadMix           []*ScenarioWeight   `json:"workload_mix"`
+	Seed                  int64               `json:"seed"`
+	Signer                string              `json:"signer"`
+	Presign               bool                `json:"presign"`
+	DoubleSpend           *DoubleSpendConfig  `json:"double_spend"`
+	Mutation              *MutationConfig     `json:"mutation"`
+	Verification          *VerificationConfig `json:"verification"`
+	Propagation           *PropagationConfig  `json:"propagation"`
+	Routing               *RoutingConfig      `json:"routing"`
+	Transport             *TransportConfig    `json:"transport"`
+	Timeouts              *TimeoutConfig      `json:"timeouts"`
+	RateLimits            *RateLimitConfig    `json:"rate_limits"`
+	Capacity              *CapacityConfig     `json:"capacity"`
+	Soak                  *SoakConfig         `json:"soak"`
+	Assertions            *AssertionConfig    `json:"assertions"`
+	Labels                map[string]string   `json:"labels"`
+	Recording             *RecordingConfig    `json:"recording"`
+	Liveness              *LivenessConfig     `json:"liveness"`
 }
 
 type NodeConfig struct {
-	IP            string `json:"ip"`
-	Port          string `json:"port"`
-	ID            string `json:"id"`
-	Signature     string `json:"signature"`
+	IP            string          `json:"ip"`
+	Port          string          `json:"port"`
+	ID            string          `json:"id"`
+	Signature     string          `json:"signature"`
+	AddressBase   string          `json:"address_base"`
+	KeyIdentifier string          `json:"key_identifier"`
+	TLS           *NodeTLSConfig  `json:"tls"`
+	RateLimits    *OperationRates `json:"rate_limits"`
+}
+
+type ReceiverConfig struct {
 	AddressBase   string `json:"address_base"`
 	KeyIdentifier string `json:"key_identifier"`
 }
+
+func nodeAddress(nodeConfig *NodeConfig) string {
+	return fmt.Sprintf("%slal%s", nodeConfig.AddressBase, nodeConfig.KeyIdentifier)
+}
+
+// Count is the number of conflicting transaction pairs injected by every node
+type DoubleSpendConfig struct {
+	Count               uint `json:"count"`
+	TimeoutSeconds      uint `json:"timeout_seconds"`
+	PollIntervalSeconds uint `json:"poll_interval_seconds"`
+}
+
+// Fraction is the share of signed load transactions that are submitted in a corrupted form instead.
+// ConfirmSeconds is the wait after the run before the refused transactions are looked up in the outputs.
+type MutationConfig struct {
+	Fraction       float64  `json:"fraction"`
+	Kinds          []string `json:"kinds"`
+	ConfirmSeconds uint     `json:"confirm_seconds"`
+}
+
+type VerificationConfig struct {
+	StableTimeoutSeconds uint `json:"stable_timeout_seconds"`
+	PollIntervalSeconds  uint `json:"poll_interval_seconds"`
+}
+
+// Every SampleEvery-th submitted transaction is followed until it is visible on all other nodes.
+// TimeoutSeconds counts from the submission of the transaction.
+type PropagationConfig struct {
+	SampleEvery    uint `json:"sample_every"`
+	TimeoutSeconds uint `json:"timeout_seconds"`
+	PollIntervalMs uint `json:"poll_interval_ms"`
+}
+
+// A single weighted entry of the workload mix. Width is the number of outputs
+// of a fan out payment or the number of inputs of a consolidation.
+type ScenarioWeight struct {
+	Scenario string `json:"scenario"`
+	Weight   uint   `json:"weight"`
+	Width    uint   `json:"width"`
+}
+
+// Validates the config and fills in the defaults for the optional values
+func (c *LoadConfig) Validate() error {
+	if len(c.NodeConfigs) == 0 {
+		return errors.New("No nodes configured")
+	}
+
+	if c.OutputsPerTransaction == 0 {
+		return errors.New("outputs_per_transaction must be greater than 0")
+	}
+
+	switch c.Topology {
+	case TopologyNone:
+	case TopologyRing, TopologyMesh, TopologyRandom:
+		if len(c.NodeConfigs) < 2 {
+			return fmt.Errorf("Topology %q needs at least 2 nodes", c.Topology)
+		}
+	default:
+		return fmt.Errorf("Unknown topology %q", c.Topology)
+	}
+
+	if c.Topology == TopologyNone && c.ReceiverAddressBase == "" && len(c.Receivers) == 0 && c.GeneratedReceivers == 0 {
+		return errors.New("No receivers configured")
+	}
+
+	if c.GeneratedReceivers > 0 && c.ReceiverGeneratorNode >= uint(len(c.NodeConfigs)) {
+		return fmt.Errorf("receiver_generator_node %d is not a configured node", c.ReceiverGeneratorNode)
+	}
+
+	switch c.ReceiverStrategy {
+	case "":
+		c.ReceiverStrategy = StrategyRoundRobin
+	case StrategyRoundRobin, StrategyRandom:
+	case StrategyZipf:
+		if c.ZipfExponent == 0 {
+			c.ZipfExponent = defaultZipfExponent
+		}
+		if c.ZipfExponent <= 1 {
+			return errors.New("zipf_exponent must be greater than 1")
+		}
+	default:
+		return fmt.Errorf("Unknown receiver strategy %q", c.ReceiverStrategy)
+	}
+
+	if c.DoubleSpend != nil {
+		if len(c.NodeConfigs) < 2 {
+			return errors.New("Double spends need at least 2 nodes")
+		}
+		if c.DoubleSpend.TimeoutSeconds == 0 {
+			c.DoubleSpend.TimeoutSeconds = defaultDoubleSpendTimeout
+		}
+		if c.DoubleSpend.PollIntervalSeconds == 0 {
+			c.DoubleSpend.PollIntervalSeconds = defaultDoubleSpendPollInterval
+		}
+	}
+
+	if c.Mutation != nil {
+		if c.Mutation.Fraction <= 0 || c.Mutation.Fraction > 1 {
+			return errors.New("Mutation fraction must be in (0, 1]")
+		}
+		if len(c.Mutation.Kinds) == 0 {
+			c.Mutation.Kinds = allMutations
+		}
+		if c.Mutation.ConfirmSeconds == 0 {
+			c.Mutation.ConfirmSeconds = defaultMutationConfirmSeconds
+		}
+		for _, kind := range c.Mutation.Kinds {
+			switch kind {
+			case MutationSignature, MutationAmount, MutationInput, MutationPayloadHash, MutationDuplicate:
+			default:
+				return fmt.Errorf("Unknown mutation %q", kind)
+			}
+		}
+	}
+
+	if c.Verification != nil {
+		if c.Verification.StableTimeoutSeconds == 0 {
+			c.Verification.StableTimeoutSeconds = defaultStableTimeout
+		}
+		if c.Verification.PollIntervalSeconds == 0 {
+			c.Verification.PollIntervalSeconds = defaultStablePollInterval
+		}
+	}
+
+	if c.Propagation != nil {
+		if len(c.NodeConfigs) < 2 {
+			return errors.New("Propagation measurement needs at least 2 nodes")
+		}
+		if c.Propagation.SampleEvery == 0 {
+			c.Propagation.SampleEvery = defaultPropagationSampleEvery
+		}
+		if c.Propagation.TimeoutSeconds == 0 {
+			c.Propagation.TimeoutSeconds = defaultPropagationTimeout
+		}
+		if c.Propagation.PollIntervalMs == 0 {
+			c.Propagation.PollIntervalMs = defaultPropagationPollIntervalMs
+		}
+	}
+
+	switch c.Signer {
+	case "":
+		c.Signer = SignerNode
+	case SignerNode, SignerLocal:
+	default:
+		return fmt.Errorf("Unknown signer %q", c.Signer)
+	}
+
+	if c.Capacity != nil {
+		if err := c.Capacity.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Soak != nil {
+		if err := c.Soak.validate(c); err != nil {
+			return err
+		}
+	}
+
+	if c.Recording != nil && c.Recording.Path == "" {
+		return errors.New("Recording path must be set")
+	}
+
+	if c.Liveness != nil {
+		if err := c.Liveness.validate(); err != nil {
+			return err
+		}
+	}
+
+	if c.Assertions != nil {
+		if err := c.Assertions.validate(c); err != nil {
+			return err
+		}
+	}
+
+	if c.Routing != nil {
+		if err := c.Routing.validate(c); err != nil {
+			return err
+		}
+	}
+
+	for _, nodeConfig := range c.allNodeConfigs() {
+		if nodeConfig.TLS != nil {
+			if err := nodeConfig.TLS.load(); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("Invalid tls config of node %s", nodeConfig.ID))
+			}
+		}
+		if nodeConfig.RateLimits != nil {
+			if err := nodeConfig.RateLimits.validate(); err != nil {
+				return errors.Wrap(err, fmt.Sprintf("Invalid rate limits of node %s", nodeConfig.ID))
+			}
+		}
+	}
+
+	if c.RateLimits != nil {
+		for _, rates := range []*OperationRates{c.RateLimits.Global, c.RateLimits.Node} {
+			if rates == nil {
+				continue
+			}
+			if err := rates.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.Transport == nil {
+		c.Transport = &TransportConfig{}
+	}
+	c.Transport.setDefaults(c.GoroutineCount)
+
+	if c.Timeouts == nil {
+		c.Timeouts = &TimeoutConfig{}
+	}
+	if err := c.Timeouts.validate(); err != nil {
+		return err
+	}
+
+	if c.OutputAmount == 0 {
+		c.OutputAmount = 1
+	}
+
+	if len(c.WorkloadMix) == 0 {
+		c.WorkloadMix = []*ScenarioWeight{{Scenario: ScenarioTransfer, Weight: 1}}
+	}
+
+	// Without any weight every transaction would be of the first scenario
+	totalWeight := uint(0)
+	for _, scenario := range c.WorkloadMix {
+		totalWeight += scenario.Weight
+	}
+	if totalWeight == 0 {
+		return errors.New("The weights of the workload mix add up to 0")
+	}
+
+	for _, scenario := range c.WorkloadMix {
+		switch scenario.Scenario {
+		case ScenarioTransfer:
+		case ScenarioFanOut, ScenarioConsolidation:
+			if scenario.Width == 0 {
+				scenario.Width = defaultScenarioWidth
+			}
+			if scenario.Scenario == ScenarioFanOut && c.OutputAmount < scenario.Width {
+				return fmt.Errorf("Fan out width %d needs an output_amount of at least %d, got %d", scenario.Width, scenario.Width, c.OutputAmount)
+			}
+		default:
+			return fmt.Errorf("Unknown scenario %q in workload mix", scenario.Scenario)
+		}
+	}
+
+	return nil
+}
+
+// Returns a copy of the config without the node signatures that authenticate the API requests
+func (c *LoadConfig) Redacted() (*LoadConfig, error) {
+	copied, err := c.copy()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeConfig := range copied.allNodeConfigs() {
+		if nodeConfig.Signature != "" {
+			nodeConfig.Signature = redacted
+		}
+	}
+
+	return copied, nil
+}
+
+// Deep copy of the config. The loaded TLS configs are not copied, so the copy must be validated
+// before it is used.
+func (c *LoadConfig) copy() (*LoadConfig, error) {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to marshal config")
+	}
+
+	var copied *LoadConfig
+	if err := json.Unmarshal(content, &copied); err != nil {
+		return nil, errors.Wrap(err, "Failed to unmarshal config")
+	}
+
+	return copied, nil
+}
@@ -0,0 +1,467 @@
+package load
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"millix-performance-test/client"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Time between the last worker becoming ready and the start, so that every worker sees the start
+// before it is due
+const coordinatorStartDelay = 3 * time.Second
+
+// Splits a load test over several worker processes and merges their reports into one result. The
+// coordinator funds the nodes itself, every node is then loaded by exactly one worker. The workers
+// have the timeout to become ready after the funding, and again to report after the start. Every
+// control request must carry the shared token.
+type Coordinator struct {
+	config       *LoadConfig
+	workerCount  int
+	token        string
+	timeout      time.Duration
+	orchestrator *Orchestrator
+
+	mu          sync.Mutex
+	funded      bool
+	workers     []string
+	assignments map[string]*LoadConfig
+	ready       map[string]bool
+	startAt     time.Time
+	started     chan struct{}
+	reports     map[string]*WorkerReport
+	done        chan error
+}
+
+func NewCoordinator(config *LoadConfig, workerCount int, token string, timeout time.Duration) (*Coordinator, error) {
+	if workerCount < 1 {
+		return nil, errors.New("At least one worker is needed")
+	}
+	if token == "" {
+		return nil, errors.New("A shared token is needed")
+	}
+	if timeout <= 0 {
+		return nil, errors.New("The worker timeout must be greater than 0")
+	}
+	if workerCount > len(config.NodeConfigs) {
+		return nil, fmt.Errorf("%d workers can not share %d nodes", workerCount, len(config.NodeConfigs))
+	}
+
+	// These features need a view of all the nodes in one process
+	switch {
+	case config.Topology != TopologyNone:
+		return nil, errors.New("A topology can not be split over workers")
+	case config.DoubleSpend != nil:
+		return nil, errors.New("Double spends can not be split over workers")
+	case config.Verification != nil:
+		return nil, errors.New("Ledger verification can not be split over workers")
+	case config.Propagation != nil:
+		return nil, errors.New("Propagation measurement can not be split over workers")
+	}
+
+	return &Coordinator{
+		config:       config,
+		workerCount:  workerCount,
+		token:        token,
+		timeout:      timeout,
+		orchestrator: NewOrchestrator(config),
+		workers:      make([]string, 0, workerCount),
+		ready:        make(map[string]bool),
+		started:      make(chan struct{}),
+		reports:      make(map[string]*WorkerReport),
+		done:         make(chan error, 1),
+	}, nil
+}
+
+// Serves the control protocol on listen, funds the nodes and waits until every worker reported
+func (c *Coordinator) Run(listen string) (*Result, error) {
+	defer c.orchestrator.Close()
+
+	metadata, err := newMetadata(c.config)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to collect run metadata")
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to listen")
+	}
+	server := &http.Server{Handler: c.handler()}
+	go server.Serve(listener)
+	// Lets the last worker receive the answer to its report
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	fmt.Printf("[Coordinator] Run %s. Listening on %s for %d workers.\n", metadata.RunID, listener.Addr(), c.workerCount)
+
+	// The coordinator monitors the liveness of all the nodes for the workers
+	c.orchestrator.startLiveness(phaseFunding)
+
+	if err := c.orchestrator.ensureFunds(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare initial funds")
+	}
+
+	c.mu.Lock()
+	c.funded = true
+	c.mu.Unlock()
+	c.orchestrator.setPhase(phaseWorkers)
+
+	if err := c.wait(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	res := c.merge()
+	res.Metadata = metadata
+	for _, workerID := range c.workers {
+		res.Metadata.Nodes = append(res.Metadata.Nodes, c.reports[workerID].NodeChecks...)
+	}
+	c.mu.Unlock()
+	res.Liveness = c.orchestrator.stopLiveness()
+
+	if c.config.Assertions != nil {
+		res.Assertions = c.config.Assertions.evaluate(res)
+	}
+
+	return res, nil
+}
+
+// Waits for the outcome of the run, failing it when the workers are not ready within the timeout
+// or have not all reported within the timeout after the start
+func (c *Coordinator) wait() error {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	started := c.started
+	for {
+		select {
+		case err := <-c.done:
+			return err
+		case <-started:
+			c.mu.Lock()
+			startAt := c.startAt
+			c.mu.Unlock()
+
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(time.Until(startAt) + c.timeout)
+			started = nil
+		case <-timer.C:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			if started != nil {
+				return fmt.Errorf("Workers not ready within %s: %v", c.timeout, c.missing(c.ready))
+			}
+			reported := make(map[string]bool)
+			for workerID := range c.reports {
+				reported[workerID] = true
+			}
+			return fmt.Errorf("Workers did not report within %s of the start: %v", c.timeout, c.missing(reported))
+		}
+	}
+}
+
+// Lists the registered workers missing from done and how many never registered. Must be called with
+// the lock held.
+func (c *Coordinator) missing(done map[string]bool) []string {
+	missing := make([]string, 0)
+	for _, workerID := range c.workers {
+		if !done[workerID] {
+			missing = append(missing, workerID)
+		}
+	}
+	if unregistered := c.workerCount - len(c.workers); unregistered > 0 {
+		missing = append(missing, fmt.Sprintf("%d unregistered", unregistered))
+	}
+
+	return missing
+}
+
+func (c *Coordinator) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/register", c.handleRegister)
+	mux.HandleFunc("/assignment", c.handleAssignment)
+	mux.HandleFunc("/ready", c.handleReady)
+	mux.HandleFunc("/start", c.handleStart)
+	mux.HandleFunc("/report", c.handleReport)
+	mux.HandleFunc("/fail", c.handleFail)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := []byte(c.token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(workerTokenHeader)), token) != 1 {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var registration *workerRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil || registration.WorkerID == "" {
+		http.Error(w, "Invalid registration", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.registered(registration.WorkerID) {
+		return
+	}
+	if len(c.workers) == c.workerCount {
+		http.Error(w, "All workers are registered", http.StatusConflict)
+		return
+	}
+
+	c.workers = append(c.workers, registration.WorkerID)
+	fmt.Printf("[Coordinator] Worker %s registered. %d of %d.\n", registration.WorkerID, len(c.workers), c.workerCount)
+}
+
+// Answers 204 until the nodes are funded and all the workers registered
+func (c *Coordinator) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	workerID := r.URL.Query().Get("worker_id")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.registered(workerID) {
+		http.Error(w, "Unknown worker", http.StatusNotFound)
+		return
+	}
+	if !c.funded || len(c.workers) < c.workerCount {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if c.assignments == nil {
+		assignments, err := c.split()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			c.finish(err)
+			return
+		}
+		c.assignments = assignments
+	}
+
+	writeJSON(w, &workerAssignment{Config: c.assignments[workerID]})
+}
+
+func (c *Coordinator) handleReady(w http.ResponseWriter, r *http.Request) {
+	var registration *workerRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.registered(registration.WorkerID) {
+		http.Error(w, "Unknown worker", http.StatusNotFound)
+		return
+	}
+
+	c.ready[registration.WorkerID] = true
+	fmt.Printf("[Coordinator] Worker %s is ready.\n", registration.WorkerID)
+
+	if len(c.ready) == c.workerCount && c.startAt.IsZero() {
+		c.startAt = time.Now().Add(coordinatorStartDelay)
+		close(c.started)
+		fmt.Printf("[Coordinator] All workers are ready. Starting in %s.\n", coordinatorStartDelay)
+		c.orchestrator.setPhase(phaseLoad)
+	}
+}
+
+// The start is sent as a delay, so the clocks of the worker machines do not need to agree
+func (c *Coordinator) handleStart(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	startAt := c.startAt
+	c.mu.Unlock()
+
+	if startAt.IsZero() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, &workerStart{StartInMs: int64(time.Until(startAt) / time.Millisecond)})
+}
+
+func (c *Coordinator) handleReport(w http.ResponseWriter, r *http.Request) {
+	var report *WorkerReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, "Invalid report", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.registered(report.WorkerID) {
+		http.Error(w, "Unknown worker", http.StatusNotFound)
+		return
+	}
+
+	c.reports[report.WorkerID] = report
+	fmt.Printf("[Coordinator] Worker %s reported %d nodes.\n", report.WorkerID, len(report.Nodes))
+
+	if len(c.reports) == c.workerCount {
+		c.finish(nil)
+	}
+}
+
+func (c *Coordinator) handleFail(w http.ResponseWriter, r *http.Request) {
+	var failure *workerFailure
+	if err := json.NewDecoder(r.Body).Decode(&failure); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	registered := c.registered(failure.WorkerID)
+	c.mu.Unlock()
+
+	if !registered {
+		http.Error(w, "Unknown worker", http.StatusNotFound)
+		return
+	}
+
+	fmt.Printf("[Coordinator] ERROR. Worker %s failed: %s.\n", failure.WorkerID, failure.Error)
+	c.finish(fmt.Errorf("Worker %s failed: %s", failure.WorkerID, failure.Error))
+}
+
+// Ends the run with the first outcome
+func (c *Coordinator) finish(err error) {
+	select {
+	case c.done <- err:
+	default:
+	}
+}
+
+// Must be called with the lock held
+func (c *Coordinator) registered(workerID string) bool {
+	for _, registered := range c.workers {
+		if registered == workerID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Gives every worker every n-th node. The nodes of the other workers stay available for routing,
+// and the global rate limits are shared evenly. Must be called with the lock held.
+func (c *Coordinator) split() (map[string]*LoadConfig, error) {
+	assignments := make(map[string]*LoadConfig)
+	for i, workerID := range c.workers {
+		config, err := c.config.copy()
+		if err != nil {
+			return nil, err
+		}
+
+		nodeConfigs := make([]*NodeConfig, 0)
+		otherNodes := make([]*NodeConfig, 0)
+		for j, nodeConfig := range config.NodeConfigs {
+			if j%c.workerCount == i {
+				nodeConfigs = append(nodeConfigs, nodeConfig)
+			} else {
+				otherNodes = append(otherNodes, nodeConfig)
+			}
+		}
+		config.NodeConfigs = nodeConfigs
+
+		if config.Routing != nil {
+			config.Routing.Nodes = append(config.Routing.Nodes, otherNodes...)
+		}
+
+		if config.RateLimits != nil && config.RateLimits.Global != nil {
+			global := config.RateLimits.Global
+			global.Sign /= float64(c.workerCount)
+			global.Submit /= float64(c.workerCount)
+			global.Query /= float64(c.workerCount)
+		}
+
+		if config.Recording != nil {
+			config.Recording.Path = fmt.Sprintf("%s.%s", config.Recording.Path, workerID)
+		}
+
+		// Every worker generates its own receivers on its first node
+		config.ReceiverGeneratorNode = 0
+		config.Assertions = nil
+		config.Liveness = nil
+
+		assignments[workerID] = config
+	}
+
+	return assignments, nil
+}
+
+// Merges the worker reports. The timed window spans from the first start to the last end, both
+// placed relative to the start signal on the clock of the coordinator.
+// Must be called with the lock held after all reports arrived.
+func (c *Coordinator) merge() *Result {
+	var startTime, endTime time.Time
+	nodeResults := make([]*NodeResult, 0, len(c.config.NodeConfigs))
+	connections := make(map[string]*client.ConnectionStats)
+
+	for _, report := range c.reports {
+		workerStart := c.startAt.Add(time.Duration(report.StartOffsetSeconds * float64(time.Second)))
+		workerEnd := workerStart.Add(time.Duration(report.DurationSeconds * float64(time.Second)))
+		if startTime.IsZero() || workerStart.Before(startTime) {
+			startTime = workerStart
+		}
+		if workerEnd.After(endTime) {
+			endTime = workerEnd
+		}
+
+		samples := make(map[string]*NodeSamples)
+		for _, nodeSamples := range report.Samples {
+			samples[nodeSamples.Address] = nodeSamples
+		}
+
+		for _, nodeResult := range report.Nodes {
+			nodeResult.latencies = make(map[string]*latencyRecorder)
+			nodeResult.waits = make(map[string]*latencyRecorder)
+			if nodeSamples, ok := samples[nodeResult.Address]; ok {
+				for scenario, latencies := range nodeSamples.Latencies {
+					nodeResult.latencies[scenario] = newLatencyRecorderFromMs(latencies)
+				}
+				for class, waits := range nodeSamples.Waits {
+					nodeResult.waits[class] = newLatencyRecorderFromMs(waits)
+				}
+			}
+			nodeResults = append(nodeResults, nodeResult)
+		}
+
+		for nodeID, stats := range report.Connections {
+			if _, ok := connections[nodeID]; !ok {
+				connections[nodeID] = &client.ConnectionStats{}
+			}
+			connections[nodeID].Add(stats)
+		}
+	}
+
+	res := summariseNodes(c.config, startTime, endTime, nodeResults)
+	res.Connections = connections
+
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		fmt.Printf("[Coordinator] ERROR. Failed to write response: %s.\n", err)
+	}
+}
@@ -0,0 +1,299 @@
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+const testToken = "secret"
+
+func testConfig(t *testing.T, nodeCount int) *LoadConfig {
+	config := &LoadConfig{
+		TransactionPerNode:    10,
+		OutputsPerTransaction: 10,
+		OutputAmount:          100,
+		GoroutineCount:        1,
+		ReceiverAddressBase:   "receiver",
+		ReceiverKeyIdentifier: "receiver",
+	}
+	for i := 0; i < nodeCount; i++ {
+		config.NodeConfigs = append(config.NodeConfigs, &NodeConfig{
+			IP:            "127.0.0.1",
+			Port:          fmt.Sprint(5500 + i),
+			ID:            fmt.Sprintf("node%d", i),
+			Signature:     fmt.Sprintf("signature%d", i),
+			AddressBase:   fmt.Sprintf("base%d", i),
+			KeyIdentifier: fmt.Sprintf("key%d", i),
+		})
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+// A coordinator whose nodes are already funded
+func testCoordinator(t *testing.T, config *LoadConfig, workerCount int) *Coordinator {
+	c, err := NewCoordinator(config, workerCount, testToken, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.funded = true
+
+	return c
+}
+
+func nodeIDsOf(nodeConfigs []*NodeConfig) []string {
+	ids := make([]string, 0, len(nodeConfigs))
+	for _, nodeConfig := range nodeConfigs {
+		ids = append(ids, nodeConfig.ID)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestCoordinatorProtocol(t *testing.T) {
+	config := testConfig(t, 3)
+	c := testCoordinator(t, config, 2)
+	server := httptest.NewServer(c.handler())
+	defer server.Close()
+
+	workerIDs := []string{"w1", "w2"}
+	assignments := make(chan *LoadConfig, len(workerIDs))
+	errs := make(chan error, len(workerIDs))
+
+	for _, workerID := range workerIDs {
+		go func(workerID string) {
+			w := &worker{coordinator: server.URL, id: workerID, token: testToken, httpClient: server.Client()}
+
+			var assignment *workerAssignment
+			var start *workerStart
+			steps := []func() error{
+				func() error { return w.post("/register", &workerRegistration{WorkerID: w.id}) },
+				func() error { return w.poll("/assignment", time.Millisecond, &assignment) },
+				func() error { return w.post("/ready", &workerRegistration{WorkerID: w.id}) },
+				func() error { return w.poll("/start", time.Millisecond, &start) },
+				func() error {
+					if start.StartInMs <= 0 || start.StartInMs > coordinatorStartDelay.Milliseconds() {
+						return fmt.Errorf("Start in %d ms", start.StartInMs)
+					}
+
+					nodes := make([]*NodeResult, 0)
+					for _, nodeConfig := range assignment.Config.NodeConfigs {
+						nodes = append(nodes, &NodeResult{Address: nodeAddress(nodeConfig), TotalTransactions: 10})
+					}
+					return w.post("/report", &WorkerReport{WorkerID: w.id, DurationSeconds: 1, Nodes: nodes})
+				},
+			}
+
+			for _, step := range steps {
+				if err := step(); err != nil {
+					errs <- fmt.Errorf("Worker %s: %s", w.id, err)
+					return
+				}
+			}
+			assignments <- assignment.Config
+		}(workerID)
+	}
+
+	select {
+	case err := <-c.done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("The workers did not finish")
+	}
+
+	// Every node is loaded by exactly one worker
+	loaded := make([]*NodeConfig, 0)
+	for range workerIDs {
+		assignment := <-assignments
+		if len(assignment.NodeConfigs) == 0 {
+			t.Error("A worker got no nodes")
+		}
+		loaded = append(loaded, assignment.NodeConfigs...)
+	}
+	if ids, want := nodeIDsOf(loaded), nodeIDsOf(config.NodeConfigs); fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("Loaded nodes %v, want %v", ids, want)
+	}
+
+	c.mu.Lock()
+	res := c.merge()
+	c.mu.Unlock()
+	if res.TotalTransactions != 30 || len(res.Nodes) != 3 {
+		t.Errorf("Merged %d transactions of %d nodes, want 30 of 3", res.TotalTransactions, len(res.Nodes))
+	}
+}
+
+func TestCoordinatorRefusesRequests(t *testing.T) {
+	c := testCoordinator(t, testConfig(t, 2), 1)
+	server := httptest.NewServer(c.handler())
+	defer server.Close()
+
+	request := func(method, path, token string, body interface{}) int {
+		content, _ := json.Marshal(body)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(workerTokenHeader, token)
+
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		status int
+	}{
+		{"missing token", http.MethodPost, "/register", "", &workerRegistration{WorkerID: "w1"}, http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "/register", "wrong", &workerRegistration{WorkerID: "w1"}, http.StatusUnauthorized},
+		{"assignment of an unknown worker", http.MethodGet, "/assignment?worker_id=w1", testToken, nil, http.StatusNotFound},
+		{"failure of an unknown worker", http.MethodPost, "/fail", testToken, &workerFailure{WorkerID: "w1", Error: "boom"}, http.StatusNotFound},
+		{"register", http.MethodPost, "/register", testToken, &workerRegistration{WorkerID: "w1"}, http.StatusOK},
+		{"register again", http.MethodPost, "/register", testToken, &workerRegistration{WorkerID: "w1"}, http.StatusOK},
+		{"register too many", http.MethodPost, "/register", testToken, &workerRegistration{WorkerID: "w2"}, http.StatusConflict},
+		{"start before ready", http.MethodGet, "/start?worker_id=w1", testToken, nil, http.StatusNoContent},
+		{"ready of an unknown worker", http.MethodPost, "/ready", testToken, &workerRegistration{WorkerID: "w2"}, http.StatusNotFound},
+		{"report of an unknown worker", http.MethodPost, "/report", testToken, &WorkerReport{WorkerID: "w2"}, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		if status := request(test.method, test.path, test.token, test.body); status != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, status, test.status)
+		}
+	}
+
+	select {
+	case err := <-c.done:
+		t.Fatalf("Run ended by refused requests: %v", err)
+	default:
+	}
+
+	if status := request(http.MethodPost, "/fail", testToken, &workerFailure{WorkerID: "w1", Error: "boom"}); status != http.StatusOK {
+		t.Fatalf("Failure status %d", status)
+	}
+	if err := <-c.done; err == nil {
+		t.Error("The failure of a registered worker did not fail the run")
+	}
+}
+
+func TestCoordinatorSplit(t *testing.T) {
+	config := testConfig(t, 5)
+	config.RateLimits = &RateLimitConfig{Global: &OperationRates{Sign: 10, Submit: 20, Query: 30}}
+	config.Routing = &RoutingConfig{Nodes: []*NodeConfig{{IP: "127.0.0.1", Port: "5600", ID: "router", Signature: "signature"}}}
+	config.Assertions = &AssertionConfig{MinTps: 1}
+
+	c := testCoordinator(t, config, 2)
+	c.workers = []string{"w1", "w2"}
+
+	assignments, err := c.split()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		workerID string
+		loaded   []string
+		routing  []string
+	}{
+		{"w1", []string{"node0", "node2", "node4"}, []string{"node1", "node3", "router"}},
+		{"w2", []string{"node1", "node3"}, []string{"node0", "node2", "node4", "router"}},
+	}
+
+	for _, test := range tests {
+		assignment := assignments[test.workerID]
+
+		if loaded := nodeIDsOf(assignment.NodeConfigs); fmt.Sprint(loaded) != fmt.Sprint(test.loaded) {
+			t.Errorf("%s loads %v, want %v", test.workerID, loaded, test.loaded)
+		}
+		if routing := nodeIDsOf(assignment.Routing.Nodes); fmt.Sprint(routing) != fmt.Sprint(test.routing) {
+			t.Errorf("%s routes over %v, want %v", test.workerID, routing, test.routing)
+		}
+
+		// The global limits are shared, so the workers together stay within them
+		global := assignment.RateLimits.Global
+		if global.Sign != 5 || global.Submit != 10 || global.Query != 15 {
+			t.Errorf("%s global limits %+v, want half of the configured ones", test.workerID, global)
+		}
+
+		if assignment.Assertions != nil {
+			t.Errorf("%s evaluates assertions", test.workerID)
+		}
+	}
+
+	if global := config.RateLimits.Global; global.Sign != 10 || len(config.NodeConfigs) != 5 || len(config.Routing.Nodes) != 1 {
+		t.Error("Splitting changed the coordinator's config")
+	}
+}
+
+func TestCoordinatorMerge(t *testing.T) {
+	c := testCoordinator(t, testConfig(t, 2), 2)
+	c.workers = []string{"w1", "w2"}
+	c.startAt = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	c.reports["w1"] = &WorkerReport{
+		WorkerID:           "w1",
+		StartOffsetSeconds: 0.5,
+		DurationSeconds:    10,
+		Nodes:              []*NodeResult{{Address: "a", TotalTransactions: 100, Failed: 2}},
+		Samples: []*NodeSamples{{
+			Address:   "a",
+			Latencies: map[string][]float64{ScenarioTransfer: {10, 20}},
+		}},
+	}
+	c.reports["w2"] = &WorkerReport{
+		WorkerID:           "w2",
+		StartOffsetSeconds: 1,
+		DurationSeconds:    11,
+		Nodes:              []*NodeResult{{Address: "b", TotalTransactions: 110}},
+		Samples: []*NodeSamples{{
+			Address:   "b",
+			Latencies: map[string][]float64{ScenarioTransfer: {30}},
+		}},
+	}
+
+	res := c.merge()
+
+	// From the first start to the last end
+	if start := res.StartTime.Sub(c.startAt); start != 500*time.Millisecond {
+		t.Errorf("Window starts %s after the start signal, want 500ms", start)
+	}
+	if end := res.EndTime.Sub(c.startAt); end != 12*time.Second {
+		t.Errorf("Window ends %s after the start signal, want 12s", end)
+	}
+
+	if res.TotalTransactions != 210 || res.Failed != 2 {
+		t.Errorf("%d transactions and %d failed, want 210 and 2", res.TotalTransactions, res.Failed)
+	}
+	if tps := res.AchievedTps; tps != 210/11.5 {
+		t.Errorf("Achieved %g tx/s, want %g", tps, 210/11.5)
+	}
+
+	// The percentiles are computed over the samples of all workers
+	transfer := res.Scenarios[ScenarioTransfer]
+	if transfer == nil || transfer.Count != 3 || transfer.Latency.MaxMs != 30 {
+		t.Errorf("Transfer latencies %+v, want the 3 samples of both workers", transfer)
+	}
+}
@@ -0,0 +1,225 @@
+package load
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"math"
+	"millix-performance-test/client"
+	"os"
+	"time"
+)
+
+// A pre-signed transaction of a corpus, with the node it is submitted to and the outputs it spends
+type CorpusEntry struct {
+	Node        string              `json:"node"`
+	Scenario    string              `json:"scenario"`
+	Inputs      []*CorpusInput      `json:"inputs"`
+	Transaction *client.Transaction `json:"transaction"`
+}
+
+type CorpusInput struct {
+	OutputTransactionID  string `json:"output_transaction_id"`
+	OutputPosition       uint   `json:"output_position"`
+	OutputShardID        string `json:"output_shard_id"`
+	AddressBase          string `json:"address_base"`
+	AddressKeyIdentifier string `json:"address_key_identifier"`
+}
+
+// Writes the entries as gzip compressed JSON lines
+func WriteCorpus(path string, entries []*CorpusEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create corpus file")
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	encoder := json.NewEncoder(gzipWriter)
+
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return errors.Wrap(err, "Failed to write corpus entry")
+		}
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "Failed to compress corpus")
+	}
+
+	return nil
+}
+
+func ReadCorpus(path string) ([]*CorpusEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open corpus file")
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decompress corpus")
+	}
+	defer gzipReader.Close()
+
+	entries := make([]*CorpusEntry, 0)
+	decoder := json.NewDecoder(bufio.NewReader(gzipReader))
+	for decoder.More() {
+		var entry *CorpusEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("Failed to read corpus entry %d", len(entries)))
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func newCorpusEntry(node string, pendingTx *pendingTransaction) *CorpusEntry {
+	inputs := make([]*CorpusInput, 0, len(pendingTx.unsigned.InputList))
+	for _, input := range pendingTx.unsigned.InputList {
+		inputs = append(inputs, &CorpusInput{
+			OutputTransactionID:  input.OutputTransactionID,
+			OutputPosition:       input.OutputPosition,
+			OutputShardID:        input.OutputShardID,
+			AddressBase:          input.AddressBase,
+			AddressKeyIdentifier: input.AddressKeyIdentifier,
+		})
+	}
+
+	return &CorpusEntry{
+		Node:        node,
+		Scenario:    pendingTx.scenario,
+		Inputs:      inputs,
+		Transaction: pendingTx.signed,
+	}
+}
+
+// Rebuilds the pending transaction that produced the corpus entry
+func (e *CorpusEntry) pendingTransaction() *pendingTransaction {
+	outputs := make([]*client.TransactionOutput, 0, len(e.Transaction.Outputs))
+	for _, output := range e.Transaction.Outputs {
+		outputs = append(outputs, &client.TransactionOutput{
+			OutputPosition:       output.OutputPosition,
+			AddressBase:          output.AddressBase,
+			AddressKeyIdentifier: output.AddressKeyIdentifier,
+			Amount:               output.Amount,
+			AddressVersion:       output.AddressVersion,
+		})
+	}
+
+	return &pendingTransaction{
+		scenario: e.Scenario,
+		unsigned: &client.UnsignedTransaction{
+			TransactionVersion: e.Transaction.Version,
+			InputList:          e.Transaction.Inputs,
+			OutputList:         outputs,
+		},
+		signed: e.Transaction,
+	}
+}
+
+// Funds the nodes, prepares their outputs and writes all the signed load transactions to a corpus
+// instead of submitting them
+func (o *Orchestrator) Presign(path string) error {
+	if err := o.prepareReceivers(); err != nil {
+		return errors.Wrap(err, "Failed to prepare receivers")
+	}
+
+	if err := o.ensureFunds(); err != nil {
+		return errors.Wrap(err, "Failed to prepare initial funds")
+	}
+
+	if err := o.prepareOutputs(); err != nil {
+		return errors.Wrap(err, "Failed to prepare transaction outputs")
+	}
+
+	if err := o.prepareWorkers(); err != nil {
+		return err
+	}
+
+	if err := o.presignTransactions(); err != nil {
+		return errors.Wrap(err, "Failed to pre-sign transactions")
+	}
+
+	entries := make([]*CorpusEntry, 0)
+	for _, nodeConfig := range o.nodeConfigs {
+		address := nodeAddress(nodeConfig)
+		for _, pendingTx := range o.loadClients[address].pending {
+			entries = append(entries, newCorpusEntry(address, pendingTx))
+		}
+	}
+
+	fmt.Printf("[Orchestrator] Writing %d signed transactions to %s.\n", len(entries), path)
+
+	return WriteCorpus(path, entries)
+}
+
+// Submits a corpus to the nodes it was signed for. A rate above 0 caps the total submissions per second,
+// split evenly over the nodes that have corpus entries.
+func (o *Orchestrator) SubmitCorpus(path string, rate float64) (*Result, error) {
+	if rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return nil, fmt.Errorf("Invalid submission rate %v", rate)
+	}
+
+	entries, err := ReadCorpus(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[Orchestrator] Submitting %d transactions from %s.\n", len(entries), path)
+
+	metadata, err := o.collectMetadata()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to collect run metadata")
+	}
+
+	o.startLiveness(phaseLoad)
+	defer o.stopLiveness()
+
+	for _, entry := range entries {
+		loadClient, ok := o.loadClients[entry.Node]
+		if !ok {
+			return nil, fmt.Errorf("Corpus node %s is not configured", entry.Node)
+		}
+
+		loadClient.pending = append(loadClient.pending, entry.pendingTransaction())
+	}
+
+	// Only the nodes with entries submit, so they share the whole rate
+	submitting := 0
+	for _, loadClient := range o.loadClients {
+		if len(loadClient.pending) > 0 {
+			submitting++
+		}
+	}
+
+	for _, loadClient := range o.loadClients {
+		if len(loadClient.pending) > 0 {
+			loadClient.submitRate = rate / float64(submitting)
+		}
+	}
+
+	if err := o.prepareWorkers(); err != nil {
+		return nil, err
+	}
+
+	startTime := time.Now()
+
+	nodeResults, err := o.sendTransactions()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to submit corpus")
+	}
+
+	endTime := time.Now()
+	o.confirmMutations(nodeResults)
+
+	res := o.summarise(startTime, endTime, nodeResults)
+	res.Metadata = metadata
+	res.Liveness = o.stopLiveness()
+
+	return res, nil
+}
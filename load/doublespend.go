@@ -0,0 +1,276 @@
+package load
+
+import (
+	"fmt"
+	"millix-performance-test/client"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDoubleSpendTimeout      = 120
+	defaultDoubleSpendPollInterval = 2
+)
+
+// BothRejected counts the pairs that both nodes refused on submission. Unresolved counts the pairs
+// where neither transaction became visible before the timeout, without a refusal of both.
+type DoubleSpendResult struct {
+	Attempted         uint          `json:"attempted"`
+	ConflictsDetected uint          `json:"conflicts_detected"`
+	LocalWins         uint          `json:"local_wins"`
+	PeerWins          uint          `json:"peer_wins"`
+	BothAccepted      uint          `json:"both_accepted"`
+	BothRejected      uint          `json:"both_rejected"`
+	Unresolved        uint          `json:"unresolved"`
+	ResolutionTime    *LatencyStats `json:"resolution_time"`
+
+	resolution *latencyRecorder
+	// Amounts paid out of the node by the accepted local transactions
+	node      string
+	transfers *amountCounter
+}
+
+func newDoubleSpendResult() *DoubleSpendResult {
+	return &DoubleSpendResult{
+		resolution: newLatencyRecorder(),
+		transfers:  newAmountCounter(),
+	}
+}
+
+func (r *DoubleSpendResult) merge(other *DoubleSpendResult) {
+	r.Attempted += other.Attempted
+	r.ConflictsDetected += other.ConflictsDetected
+	r.LocalWins += other.LocalWins
+	r.PeerWins += other.PeerWins
+	r.BothAccepted += other.BothAccepted
+	r.BothRejected += other.BothRejected
+	r.Unresolved += other.Unresolved
+	r.resolution.merge(other.resolution)
+	r.ResolutionTime = r.resolution.stats()
+}
+
+// Two transactions spending the same input. The local one is submitted to the load client's node,
+// the peer one to another node.
+type doubleSpendPair struct {
+	local          *client.Transaction
+	peer           *client.Transaction
+	localRecipient string
+	peerRecipient  string
+	localAddress   string
+	amount         uint
+	submitTime     time.Time
+	// Whether a node listed the transaction at any poll
+	localSeen bool
+	peerSeen  bool
+	resolved  bool
+}
+
+// Spends every reserved input twice, submitting the conflicting transactions concurrently to the
+// load client's node and to the peer node, and polls the recipients' outputs on both nodes until the
+// network settles.
+func (lc *LoadClient) InjectDoubleSpends(peerClient *client.Client, config *DoubleSpendConfig) *DoubleSpendResult {
+	res := newDoubleSpendResult()
+	res.node = lc.address
+	pairs := make([]*doubleSpendPair, 0, len(lc.doubleSpendInputs))
+
+	fmt.Printf("[Load Client] Injecting %d double spends.\n", len(lc.doubleSpendInputs))
+
+	for _, prepared := range lc.doubleSpendInputs {
+		res.Attempted++
+
+		receiver := lc.receivers.next()
+		localTx, err := lc.signSpend(prepared, receiver.addressBase, receiver.keyIdentifier)
+		if err != nil {
+			fmt.Printf("[Load Client] Failed to sign double spend: %s\n", err)
+			continue
+		}
+
+		// Paying back to the node's own address makes the two transactions differ
+		peerTx, err := lc.signSpend(prepared, lc.addressBase, lc.keyIdentifier)
+		if err != nil {
+			fmt.Printf("[Load Client] Failed to sign double spend: %s\n", err)
+			continue
+		}
+
+		var localErr, peerErr error
+		wg := sync.WaitGroup{}
+		wg.Add(2)
+
+		submitTime := time.Now()
+		go func() {
+			defer wg.Done()
+			localErr = lc.millixClient.SubmitTransaction(localTx)
+		}()
+		go func() {
+			defer wg.Done()
+			peerErr = peerClient.SubmitTransaction(peerTx)
+		}()
+
+		wg.Wait()
+
+		// A timed out submission may still be accepted, so only two refusals settle the pair
+		if rejected(localErr) && rejected(peerErr) {
+			fmt.Printf("[Load Client] Both double spends rejected on submission. Local: %s. Peer: %s\n", localErr, peerErr)
+			res.BothRejected++
+			res.resolution.record(time.Since(submitTime))
+			continue
+		}
+
+		pairs = append(pairs, &doubleSpendPair{
+			local:          localTx,
+			peer:           peerTx,
+			localRecipient: receiver.keyIdentifier,
+			peerRecipient:  lc.keyIdentifier,
+			localAddress:   receiver.address(),
+			amount:         prepared.amount,
+			submitTime:     submitTime,
+		})
+	}
+
+	lc.resolveDoubleSpends(pairs, peerClient, config, res)
+	res.ResolutionTime = res.resolution.stats()
+
+	fmt.Printf("[Load Client] Double spends done. Attempted: %d. Conflicts: %d. Both accepted: %d. Both rejected: %d. Unresolved: %d.\n", res.Attempted, res.ConflictsDetected, res.BothAccepted, res.BothRejected, res.Unresolved)
+
+	return res
+}
+
+// Reports whether the node refused the submission, as opposed to accepting it or timing out
+func rejected(submitErr error) bool {
+	if submitErr == nil {
+		return false
+	}
+	_, timedOut := client.IsTimeout(submitErr)
+
+	return !timedOut
+}
+
+func (lc *LoadClient) signSpend(prepared *preparedInput, addressBase, keyIdentifier string) (*client.Transaction, error) {
+	unsignedTx := &client.UnsignedTransaction{
+		TransactionVersion: "la0l",
+		InputList:          []*client.TransactionInput{prepared.input},
+		OutputList: []*client.TransactionOutput{{
+			AddressBase:          addressBase,
+			AddressVersion:       "lal",
+			AddressKeyIdentifier: keyIdentifier,
+			Amount:               prepared.amount,
+		}},
+	}
+
+	// Signed like the load transactions, locally or on the first worker's signing node
+	return lc.signer(lc.workers[0].sign).SignTransaction(unsignedTx, lc.keyMap, lc.publicKeyMap)
+}
+
+// Polls the stable outputs of the recipients on the load client's node and on the peer node until the
+// timeout expires or every pair is settled. A pair is settled once both transactions are listed, or once
+// both nodes list one of them and neither lists the other. At the timeout the remaining pairs are
+// classified by the transactions that any node listed at any poll, and pairs where neither transaction
+// was listed are counted as unresolved.
+func (lc *LoadClient) resolveDoubleSpends(pairs []*doubleSpendPair, peerClient *client.Client, config *DoubleSpendConfig, res *DoubleSpendResult) {
+	nodes := []*client.Client{lc.millixClient, peerClient}
+
+	deadline := time.Now().Add(time.Second * time.Duration(config.TimeoutSeconds))
+	pending := len(pairs)
+
+	for pending > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Second * time.Duration(config.PollIntervalSeconds))
+
+		visible := make([]map[string]map[string]bool, len(nodes))
+		for i := range visible {
+			visible[i] = make(map[string]map[string]bool)
+		}
+
+		for _, pair := range pairs {
+			if pair.resolved {
+				continue
+			}
+
+			localListed, err := outputListed(nodes, visible, pair.localRecipient, pair.local.TransactionID)
+			if err != nil {
+				fmt.Printf("[Load Client] Failed to poll double spend outputs: %s\n", err)
+				break
+			}
+
+			peerListed, err := outputListed(nodes, visible, pair.peerRecipient, pair.peer.TransactionID)
+			if err != nil {
+				fmt.Printf("[Load Client] Failed to poll double spend outputs: %s\n", err)
+				break
+			}
+
+			pair.localSeen = pair.localSeen || localListed > 0
+			pair.peerSeen = pair.peerSeen || peerListed > 0
+
+			settled := pair.localSeen && pair.peerSeen ||
+				localListed == len(nodes) && peerListed == 0 ||
+				peerListed == len(nodes) && localListed == 0
+			if !settled {
+				continue
+			}
+
+			pair.resolved = true
+			pending--
+			res.resolution.record(time.Since(pair.submitTime))
+			lc.classifyDoubleSpend(pair, res)
+		}
+	}
+
+	for _, pair := range pairs {
+		if pair.resolved {
+			continue
+		}
+
+		if !pair.localSeen && !pair.peerSeen {
+			fmt.Printf("[Load Client] Double spend unresolved: %s and %s.\n", pair.local.TransactionID, pair.peer.TransactionID)
+			res.Unresolved++
+			continue
+		}
+
+		fmt.Printf("[Load Client] Double spend not settled on both nodes: %s and %s.\n", pair.local.TransactionID, pair.peer.TransactionID)
+		lc.classifyDoubleSpend(pair, res)
+	}
+}
+
+func (lc *LoadClient) classifyDoubleSpend(pair *doubleSpendPair, res *DoubleSpendResult) {
+	if pair.localSeen {
+		res.transfers.add(pair.localAddress, pair.amount)
+	}
+
+	switch {
+	case pair.localSeen && pair.peerSeen:
+		fmt.Printf("[Load Client] Both double spends accepted: %s and %s.\n", pair.local.TransactionID, pair.peer.TransactionID)
+		res.BothAccepted++
+	case pair.localSeen:
+		res.ConflictsDetected++
+		res.LocalWins++
+	default:
+		res.ConflictsDetected++
+		res.PeerWins++
+	}
+}
+
+// Counts the nodes on which the transaction created an output for the recipient, caching the outputs of
+// every node per poll round
+func outputListed(nodes []*client.Client, visible []map[string]map[string]bool, keyIdentifier, transactionID string) (int, error) {
+	listed := 0
+	for i, node := range nodes {
+		transactionIDs, ok := visible[i][keyIdentifier]
+		if !ok {
+			outputs, err := node.GetUnspentTransactionOutputs(keyIdentifier)
+			if err != nil {
+				return 0, err
+			}
+
+			transactionIDs = make(map[string]bool, len(outputs))
+			for _, output := range outputs {
+				transactionIDs[output.TransactionID] = true
+			}
+			visible[i][keyIdentifier] = transactionIDs
+		}
+
+		if transactionIDs[transactionID] {
+			listed++
+		}
+	}
+
+	return listed, nil
+}
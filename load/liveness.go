@@ -0,0 +1,243 @@
+package load
+
+import (
+	"fmt"
+	"millix-performance-test/client"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLivenessInterval = 5
+
+	phaseReceivers    = "receivers"
+	phaseFunding      = "funding"
+	phaseOutputs      = "outputs"
+	phasePresign      = "presign"
+	phaseLoad         = "load"
+	phasePropagation  = "propagation"
+	phaseDoubleSpend  = "double_spend"
+	phaseVerification = "verification"
+	phaseWorkers      = "workers"
+)
+
+// Probe is the node API call of a probe, node_id or balance. A probe times out after the interval.
+type LivenessConfig struct {
+	IntervalSeconds uint   `json:"interval_seconds"`
+	Probe           string `json:"probe"`
+}
+
+type NodeLiveness struct {
+	NodeID          string           `json:"node_id"`
+	Host            string           `json:"host"`
+	Probes          uint             `json:"probe_count"`
+	Failures        uint             `json:"failed_probe_count"`
+	Availability    float64          `json:"availability"`
+	DowntimeSeconds float64          `json:"downtime_seconds"`
+	ResponseTime    *LatencyStats    `json:"response_time"`
+	Timeline        []*LivenessEvent `json:"timeline"`
+}
+
+// A change of the node state. The first event of a timeline is the state of the first probe.
+type LivenessEvent struct {
+	Time  time.Time `json:"time"`
+	Up    bool      `json:"up"`
+	Phase string    `json:"phase"`
+	Error string    `json:"error,omitempty"`
+}
+
+func (c *LivenessConfig) validate() error {
+	if c.IntervalSeconds == 0 {
+		c.IntervalSeconds = defaultLivenessInterval
+	}
+
+	switch c.Probe {
+	case "":
+		c.Probe = client.OperationNodeID
+	case client.OperationNodeID, client.OperationBalance:
+	default:
+		return fmt.Errorf("Unknown liveness probe %q", c.Probe)
+	}
+
+	return nil
+}
+
+// Pings every node at a fixed interval in the background, for the whole run
+type livenessMonitor struct {
+	config *LivenessConfig
+	nodes  []*nodeMonitor
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+	result []*NodeLiveness
+
+	mu    sync.Mutex
+	phase string
+}
+
+type nodeMonitor struct {
+	nodeConfig *NodeConfig
+	client     *client.Client
+	latencies  *latencyRecorder
+	probes     uint
+	failures   uint
+	up         bool
+	downSince  time.Time
+	downtime   time.Duration
+	timeline   []*LivenessEvent
+}
+
+func newLivenessMonitor(config *LivenessConfig, clients *clientFactory, nodeConfigs []*NodeConfig, phase string) *livenessMonitor {
+	m := &livenessMonitor{
+		config: config,
+		phase:  phase,
+		nodes:  make([]*nodeMonitor, 0, len(nodeConfigs)),
+		stop:   make(chan struct{}),
+	}
+
+	// A probe never overlaps the next one, so a hanging node is reported down at every interval
+	timeout := time.Second * time.Duration(config.IntervalSeconds)
+	for _, nodeConfig := range nodeConfigs {
+		m.nodes = append(m.nodes, &nodeMonitor{
+			nodeConfig: nodeConfig,
+			client:     clients.probeClient(nodeConfig, timeout),
+			latencies:  newLatencyRecorder(),
+			timeline:   make([]*LivenessEvent, 0),
+		})
+	}
+
+	return m
+}
+
+func (m *livenessMonitor) start() {
+	interval := time.Second * time.Duration(m.config.IntervalSeconds)
+	for _, node := range m.nodes {
+		m.wg.Add(1)
+		go func(node *nodeMonitor) {
+			defer m.wg.Done()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				m.probe(node)
+				select {
+				case <-m.stop:
+					return
+				case <-ticker.C:
+				}
+			}
+		}(node)
+	}
+}
+
+// A transition is attributed to the phase in which its probe started
+func (m *livenessMonitor) probe(node *nodeMonitor) {
+	m.mu.Lock()
+	phase := m.phase
+	m.mu.Unlock()
+
+	start := time.Now()
+	var err error
+	if m.config.Probe == client.OperationBalance {
+		_, _, err = node.client.GetBalance(nodeAddress(node.nodeConfig))
+	} else {
+		err = node.client.VerifyNodeID()
+	}
+	responseTime := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node.probes++
+	up := err == nil
+	if up {
+		node.latencies.record(responseTime)
+	} else {
+		node.failures++
+	}
+
+	if len(node.timeline) > 0 && node.up == up {
+		return
+	}
+
+	event := &LivenessEvent{Time: start, Up: up, Phase: phase}
+	if up {
+		if !node.downSince.IsZero() {
+			node.downtime += start.Sub(node.downSince)
+			node.downSince = time.Time{}
+		}
+		fmt.Printf("[Orchestrator][Liveness] Node %s is UP during %s.\n", node.nodeConfig.ID, phase)
+	} else {
+		node.downSince = start
+		event.Error = err.Error()
+		fmt.Printf("[Orchestrator][Liveness] Node %s is DOWN during %s. %s.\n", node.nodeConfig.ID, phase, err)
+	}
+
+	node.up = up
+	node.timeline = append(node.timeline, event)
+}
+
+func (m *livenessMonitor) setPhase(phase string) {
+	m.mu.Lock()
+	m.phase = phase
+	m.mu.Unlock()
+}
+
+// Stops the probes and summarises them. Later calls return the same summary.
+func (m *livenessMonitor) close() []*NodeLiveness {
+	m.once.Do(func() {
+		close(m.stop)
+		m.wg.Wait()
+
+		endTime := time.Now()
+		m.result = make([]*NodeLiveness, 0, len(m.nodes))
+		for _, node := range m.nodes {
+			downtime := node.downtime
+			if !node.downSince.IsZero() {
+				downtime += endTime.Sub(node.downSince)
+			}
+
+			liveness := &NodeLiveness{
+				NodeID:          node.nodeConfig.ID,
+				Host:            fmt.Sprintf("%s:%s", node.nodeConfig.IP, node.nodeConfig.Port),
+				Probes:          node.probes,
+				Failures:        node.failures,
+				DowntimeSeconds: downtime.Seconds(),
+				ResponseTime:    node.latencies.stats(),
+				Timeline:        node.timeline,
+			}
+			if node.probes > 0 {
+				liveness.Availability = float64(node.probes-node.failures) / float64(node.probes)
+			}
+			m.result = append(m.result, liveness)
+		}
+	})
+
+	return m.result
+}
+
+// Starts the liveness monitor of the run in its first phase if it is configured
+func (o *Orchestrator) startLiveness(phase string) {
+	if o.config.Liveness == nil || o.liveness != nil {
+		return
+	}
+
+	o.liveness = newLivenessMonitor(o.config.Liveness, o.clients, o.config.allNodeConfigs(), phase)
+	o.liveness.start()
+}
+
+// Stops the liveness monitor and returns the availability of every node, nil without a monitor
+func (o *Orchestrator) stopLiveness() []*NodeLiveness {
+	if o.liveness == nil {
+		return nil
+	}
+
+	return o.liveness.close()
+}
+
+func (o *Orchestrator) setPhase(phase string) {
+	if o.liveness != nil {
+		o.liveness.setPhase(phase)
+	}
+}
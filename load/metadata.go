@@ -0,0 +1,100 @@
+package load
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Set at build time with
+// -ldflags "-X millix-performance-test/load.Version=<version> -X millix-performance-test/load.Revision=<commit>"
+var (
+	Version  = "dev"
+	Revision = ""
+)
+
+// Describes what produced a result, so that results can be reproduced and compared
+type Metadata struct {
+	RunID         string            `json:"run_id"`
+	LoaderVersion string            `json:"loader_version"`
+	VCSRevision   string            `json:"vcs_revision"`
+	GoVersion     string            `json:"go_version"`
+	Hostname      string            `json:"hostname"`
+	CPUCount      int               `json:"cpu_count"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Config        *LoadConfig       `json:"config"`
+	Nodes         []*NodeMetadata   `json:"nodes"`
+}
+
+// The node id is the configured id when VerifyNodeID accepted it
+type NodeMetadata struct {
+	Host     string `json:"host"`
+	ID       string `json:"id"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Collects the metadata of a run before it starts. Nodes that fail the id check are recorded,
+// the run itself decides whether that is fatal.
+func (o *Orchestrator) collectMetadata() (*Metadata, error) {
+	metadata, err := newMetadata(o.config)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, nodeConfig := range o.config.allNodeConfigs() {
+		node := &NodeMetadata{
+			Host: fmt.Sprintf("%s:%s", nodeConfig.IP, nodeConfig.Port),
+			ID:   nodeConfig.ID,
+		}
+		if err := o.clients.client(nodeConfig).VerifyNodeID(); err != nil {
+			node.Error = errors.Wrap(err, "Failed to verify node id").Error()
+		} else {
+			node.Verified = true
+		}
+		metadata.Nodes = append(metadata.Nodes, node)
+	}
+
+	fmt.Printf("[Orchestrator] Run %s. Loader %s.\n", metadata.RunID, metadata.LoaderVersion)
+
+	return metadata, nil
+}
+
+// Metadata of the loader process, without node checks
+func newMetadata(loadConfig *LoadConfig) (*Metadata, error) {
+	config, err := loadConfig.Redacted()
+	if err != nil {
+		return nil, err
+	}
+
+	// The hostname is only informative
+	hostname, _ := os.Hostname()
+
+	metadata := &Metadata{
+		RunID:         newRunID(),
+		LoaderVersion: Version,
+		VCSRevision:   Revision,
+		GoVersion:     runtime.Version(),
+		Hostname:      hostname,
+		CPUCount:      runtime.NumCPU(),
+		Labels:        loadConfig.Labels,
+		Config:        config,
+		Nodes:         make([]*NodeMetadata, 0),
+	}
+
+	return metadata, nil
+}
+
+// Random 128 bit id, falling back to the start time if the system has no randomness
+func newRunID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(id)
+}
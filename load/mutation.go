@@ -0,0 +1,288 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"millix-performance-test/client"
+	"sync"
+	"time"
+)
+
+const defaultMutationConfirmSeconds = 60
+
+const (
+	// Alters the transaction signature
+	MutationSignature = "signature"
+	// Changes the amount of the first output
+	MutationAmount = "amount"
+	// Points the first input to a nonexistent transaction
+	MutationInput = "input"
+	// Alters the payload hash
+	MutationPayloadHash = "payload_hash"
+	// Submits the accepted valid transaction a second time
+	MutationDuplicate = "duplicate"
+)
+
+var allMutations = []string{MutationSignature, MutationAmount, MutationInput, MutationPayloadHash, MutationDuplicate}
+
+// Submissions that timed out are neither counted as rejected nor as accepted. A refused corrupted
+// transaction that a node lists in the outputs of its recipient after all counts as accepted.
+type MutationResult struct {
+	Injected uint                           `json:"injected"`
+	Rejected uint                           `json:"rejected"`
+	TimedOut uint                           `json:"timed_out"`
+	Kinds    map[string]*MutationKindResult `json:"kinds"`
+	Accepted []*AcceptedMutation            `json:"accepted"`
+}
+
+type MutationKindResult struct {
+	Injected uint `json:"injected"`
+	Rejected uint `json:"rejected"`
+	TimedOut uint `json:"timed_out"`
+}
+
+// An invalid transaction that the node accepted
+type AcceptedMutation struct {
+	Kind                string `json:"kind"`
+	TransactionID       string `json:"transaction_id"`
+	Node                string `json:"node"`
+	RefusedOnSubmission bool   `json:"refused_on_submission"`
+}
+
+func newMutationResult() *MutationResult {
+	return &MutationResult{
+		Kinds:    make(map[string]*MutationKindResult),
+		Accepted: make([]*AcceptedMutation, 0),
+	}
+}
+
+func (r *MutationResult) merge(other *MutationResult) {
+	r.Injected += other.Injected
+	r.Rejected += other.Rejected
+	r.TimedOut += other.TimedOut
+	r.Accepted = append(r.Accepted, other.Accepted...)
+
+	for kind, otherKind := range other.Kinds {
+		if _, ok := r.Kinds[kind]; !ok {
+			r.Kinds[kind] = &MutationKindResult{}
+		}
+		r.Kinds[kind].Injected += otherKind.Injected
+		r.Kinds[kind].Rejected += otherKind.Rejected
+		r.Kinds[kind].TimedOut += otherKind.TimedOut
+	}
+}
+
+// Decides which signed transactions get corrupted and records how the node handled them.
+// Shared by all the workers of a load client.
+type mutator struct {
+	mu       sync.Mutex
+	fraction float64
+	kinds    []string
+	rng      *rand.Rand
+	node     string
+	res      *MutationResult
+	// Corrupted transactions the node refused, until their rejection is confirmed
+	refused []*refusedMutation
+}
+
+type refusedMutation struct {
+	kind          string
+	transactionID string
+	recipient     string
+}
+
+func newMutator(config *MutationConfig, node string, seed int64) *mutator {
+	return &mutator{
+		fraction: config.Fraction,
+		kinds:    config.Kinds,
+		rng:      rand.New(rand.NewSource(seed)),
+		node:     node,
+		res:      newMutationResult(),
+	}
+}
+
+// Returns the mutation to apply to the next transaction, or an empty string to leave it intact
+func (m *mutator) next() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.rng.Float64() >= m.fraction {
+		return ""
+	}
+
+	return m.kinds[m.rng.Intn(len(m.kinds))]
+}
+
+func (m *mutator) record(kind string, tx *client.Transaction, submitErr error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.res.Kinds[kind]; !ok {
+		m.res.Kinds[kind] = &MutationKindResult{}
+	}
+
+	m.res.Injected++
+	m.res.Kinds[kind].Injected++
+
+	if _, ok := client.IsTimeout(submitErr); ok {
+		m.res.TimedOut++
+		m.res.Kinds[kind].TimedOut++
+		return
+	}
+
+	if submitErr != nil {
+		m.res.Rejected++
+		m.res.Kinds[kind].Rejected++
+
+		// The outputs of a duplicate are the ones of the valid transaction
+		if kind != MutationDuplicate && len(tx.Outputs) > 0 {
+			m.refused = append(m.refused, &refusedMutation{
+				kind:          kind,
+				transactionID: tx.TransactionID,
+				recipient:     tx.Outputs[0].AddressKeyIdentifier,
+			})
+		}
+		return
+	}
+
+	fmt.Printf("[Load Client] Invalid transaction accepted. Kind: %s. Transaction: %s.\n", kind, tx.TransactionID)
+	m.res.Accepted = append(m.res.Accepted, &AcceptedMutation{
+		Kind:          kind,
+		TransactionID: tx.TransactionID,
+		Node:          m.node,
+	})
+}
+
+// Looks the refused corrupted transactions up in the outputs of their recipients on every node. The
+// ones that any node lists are moved from the rejected to the accepted transactions.
+func (m *mutator) confirm(millixClients []*client.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	listed := make(map[string]map[string]bool)
+	for _, refused := range m.refused {
+		transactionIDs, ok := listed[refused.recipient]
+		if !ok {
+			transactionIDs = make(map[string]bool)
+			for _, millixClient := range millixClients {
+				outputs, err := millixClient.GetUnspentTransactionOutputs(refused.recipient)
+				if err != nil {
+					return err
+				}
+
+				for _, output := range outputs {
+					transactionIDs[output.TransactionID] = true
+				}
+			}
+			listed[refused.recipient] = transactionIDs
+		}
+
+		if !transactionIDs[refused.transactionID] {
+			continue
+		}
+
+		fmt.Printf("[Load Client] Refused invalid transaction listed. Kind: %s. Transaction: %s.\n", refused.kind, refused.transactionID)
+		m.res.Rejected--
+		m.res.Kinds[refused.kind].Rejected--
+		m.res.Accepted = append(m.res.Accepted, &AcceptedMutation{
+			Kind:                refused.kind,
+			TransactionID:       refused.transactionID,
+			Node:                m.node,
+			RefusedOnSubmission: true,
+		})
+	}
+	m.refused = nil
+
+	return nil
+}
+
+func (m *mutator) result() *MutationResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := newMutationResult()
+	res.merge(m.res)
+
+	return res
+}
+
+// Returns a corrupted copy of the signed transaction. The transaction id is recomputed, so that the
+// corruption is the only thing wrong with it. Duplicates are submitted unchanged.
+func mutateTransaction(kind string, tx *client.Transaction) *client.Transaction {
+	txJson, err := json.Marshal(tx)
+	if err != nil {
+		panic(fmt.Errorf("Failed to marshal to json: %s", err))
+	}
+
+	var mutated *client.Transaction
+	if err := json.Unmarshal(txJson, &mutated); err != nil {
+		panic(fmt.Errorf("Failed to unmarshal json: %s", err))
+	}
+
+	switch kind {
+	case MutationSignature:
+		if len(mutated.SignatureList) > 0 {
+			if signature, ok := mutated.SignatureList[0]["signature"].(string); ok {
+				mutated.SignatureList[0]["signature"] = tamper(signature)
+			}
+		}
+	case MutationAmount:
+		if len(mutated.Outputs) > 0 {
+			mutated.Outputs[0].Amount++
+		}
+	case MutationInput:
+		if len(mutated.Inputs) > 0 {
+			mutated.Inputs[0].OutputTransactionID = tamper(mutated.Inputs[0].OutputTransactionID)
+		}
+	case MutationPayloadHash:
+		mutated.PayloadHash = tamper(mutated.PayloadHash)
+	}
+
+	mutated.TransactionID, err = client.TransactionID(mutated)
+	if err != nil {
+		panic(fmt.Errorf("Failed to compute transaction id: %s", err))
+	}
+
+	return mutated
+}
+
+// Waits for the refused invalid transactions to settle, then checks on every node that none of them
+// made it into the outputs after all
+func (o *Orchestrator) confirmMutations(nodeResults []*NodeResult) {
+	if o.config.Mutation == nil {
+		return
+	}
+
+	fmt.Printf("[Orchestrator] Confirming the rejected invalid transactions in %d seconds.\n", o.config.Mutation.ConfirmSeconds)
+	time.Sleep(time.Second * time.Duration(o.config.Mutation.ConfirmSeconds))
+
+	millixClients := make([]*client.Client, 0)
+	for _, nodeConfig := range o.config.allNodeConfigs() {
+		millixClients = append(millixClients, o.clients.client(nodeConfig))
+	}
+
+	for _, nodeResult := range nodeResults {
+		loadClient := o.loadClients[nodeResult.Address]
+		if err := loadClient.mutator.confirm(millixClients); err != nil {
+			fmt.Printf("[Orchestrator] ERROR. Failed to confirm the rejected invalid transactions of %s: %s.\n", nodeResult.Address, err)
+			continue
+		}
+		nodeResult.Mutations = loadClient.mutator.result()
+	}
+}
+
+// Replaces the last character of a base58 string with a different base58 character
+func tamper(value string) string {
+	if value == "" {
+		return "1"
+	}
+
+	last := value[len(value)-1]
+	replacement := byte('1')
+	if last == replacement {
+		replacement = '2'
+	}
+
+	return value[:len(value)-1] + string(replacement)
+}
@@ -3,6 +3,7 @@ package load
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	"math/rand"
 	"millix-performance-test/client"
 	"time"
 )
@@ -15,7 +16,13 @@ type Orchestrator struct {
 	nodeConfigs               []*NodeConfig
 	transactionPerNode        uint
 	outputPerTransactionCount uint
+	outputAmount              uint
 	startingBalances          map[string]uint
+	config                    *LoadConfig
+	receivers                 []*receiver
+	doubleSpendResults        []*DoubleSpendResult
+	clients                   *clientFactory
+	liveness                  *livenessMonitor
 }
 
 func NewOrchestrator(config *LoadConfig) *Orchestrator {
@@ -23,19 +30,26 @@ func NewOrchestrator(config *LoadConfig) *Orchestrator {
 	loadClients := make(map[string]*LoadClient)
 	var funderClient *client.Client
 	var funderAddress string
+	clients := newClientFactory(config)
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
 
 	for i, nodeConfig := range config.NodeConfigs {
-		nodeAddress := fmt.Sprintf("%slal%s", nodeConfig.AddressBase, nodeConfig.KeyIdentifier)
-		millixClient := client.NewClient(nodeConfig.IP, nodeConfig.Port, nodeConfig.ID, nodeConfig.Signature, nodeConfig.AddressBase, nodeConfig.KeyIdentifier)
-		millixClients[nodeAddress] = millixClient
+		address := nodeAddress(nodeConfig)
+		millixClient := clients.client(nodeConfig)
+		millixClients[address] = millixClient
 
 		if i == 0 {
 			funderClient = millixClient
 			funderAddress = nodeConfig.KeyIdentifier
 		}
 
-		loadClient := NewLoadClient(millixClient, nodeConfig.IP, nodeConfig.Port, nodeConfig.ID, nodeConfig.Signature, nodeConfig.AddressBase, nodeConfig.KeyIdentifier, config.ReceiverAddressBase, config.ReceiverKeyIdentifier, config.OutputsPerTransaction, config.GoroutineCount)
-		loadClients[nodeAddress] = loadClient
+		rng := rand.New(rand.NewSource(seed + int64(i)))
+		loadClient := NewLoadClient(millixClient, clients, nodeConfig, config, rng)
+		loadClients[address] = loadClient
 	}
 
 	return &Orchestrator{
@@ -46,7 +60,11 @@ func NewOrchestrator(config *LoadConfig) *Orchestrator {
 		nodeConfigs:               config.NodeConfigs,
 		transactionPerNode:        config.TransactionPerNode,
 		outputPerTransactionCount: config.OutputsPerTransaction,
+		outputAmount:              config.OutputAmount,
 		startingBalances:          make(map[string]uint),
+		config:                    config,
+		receivers:                 configuredReceivers(config),
+		clients:                   clients,
 	}
 }
 
@@ -54,7 +72,20 @@ func (o *Orchestrator) Load() (*Result, error) {
 	totalTransactionCount := uint(len(o.nodeConfigs)) * o.transactionPerNode
 	fmt.Printf("[Orchestrator] Starting load test. %d nodes. %d total transactions.\n", len(o.nodeConfigs), totalTransactionCount)
 
-	err := o.ensureFunds()
+	metadata, err := o.collectMetadata()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to collect run metadata")
+	}
+
+	o.startLiveness(phaseReceivers)
+	defer o.stopLiveness()
+
+	err = o.prepareReceivers()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare receivers")
+	}
+
+	err = o.ensureFunds()
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to prepare initial funds")
 	}
@@ -64,30 +95,214 @@ func (o *Orchestrator) Load() (*Result, error) {
 		return nil, errors.Wrap(err, "Failed to prepare transaction outputs")
 	}
 
+	var balancesBefore *balanceSnapshot
+	if o.config.Verification != nil {
+		fmt.Printf("[Orchestrator][Verification] Taking balance snapshot before the run.\n")
+		balancesBefore, err = o.snapshotBalances(o.config.Verification)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to snapshot balances")
+		}
+	}
+
+	var probe *propagationProbe
+	if o.config.Propagation != nil {
+		probe = newPropagationProbe(o.config.Propagation, o.millixClients)
+		for _, loadClient := range o.loadClients {
+			loadClient.propagation = probe
+		}
+	}
+
+	err = o.prepareWorkers()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.config.Presign {
+		err = o.presignTransactions()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to pre-sign transactions")
+		}
+	}
+
+	if probe != nil {
+		probe.start()
+	}
+
 	startTime := time.Now()
 
-	err = o.sendTransactions()
+	nodeResults, err := o.sendTransactions()
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to perform load test")
 	}
 
 	endTime := time.Now()
-	achievedTps := float64(totalTransactionCount) / endTime.Sub(startTime).Seconds()
+
+	var propagationRes *PropagationResult
+	if probe != nil {
+		fmt.Printf("[Orchestrator] Waiting for sampled transactions to propagate.\n")
+		o.setPhase(phasePropagation)
+		propagationRes = probe.result()
+	}
+
+	var doubleSpendRes *DoubleSpendResult
+	if o.config.DoubleSpend != nil {
+		doubleSpendRes = o.injectDoubleSpends()
+	}
+
+	var verificationRes *VerificationResult
+	if o.config.Verification != nil {
+		fmt.Printf("[Orchestrator][Verification] Taking balance snapshot after the run.\n")
+		balancesAfter, err := o.snapshotBalances(o.config.Verification)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to snapshot balances")
+		}
+
+		verificationRes = o.verifyLedger(balancesBefore, balancesAfter, nodeResults, o.doubleSpendResults)
+		for _, discrepancy := range verificationRes.Discrepancies {
+			fmt.Printf("[Orchestrator][Verification] DISCREPANCY. %s.\n", discrepancy)
+		}
+	}
+
+	o.confirmMutations(nodeResults)
+
+	res := o.summarise(startTime, endTime, nodeResults)
+	res.Metadata = metadata
+	res.DoubleSpend = doubleSpendRes
+	res.Verification = verificationRes
+	res.Propagation = propagationRes
+	res.Liveness = o.stopLiveness()
+
+	if res.Mutations != nil && len(res.Mutations.Accepted) > 0 {
+		fmt.Printf("[Orchestrator] WARNING. %d invalid transactions were accepted.\n", len(res.Mutations.Accepted))
+	}
+
+	if o.config.Assertions != nil {
+		res.Assertions = o.config.Assertions.evaluate(res)
+		for _, check := range res.Assertions.Checks {
+			if !check.Passed {
+				fmt.Printf("[Orchestrator][Assertions] FAILED. %s. Limit %.3f. Actual %.3f.\n", check.Name, check.Limit, check.Actual)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Aggregates the node results of a timed window into a result
+func (o *Orchestrator) summarise(startTime, endTime time.Time, nodeResults []*NodeResult) *Result {
+	res := summariseNodes(o.config, startTime, endTime, nodeResults)
+	res.Connections = o.clients.stats()
+
+	return res
+}
+
+// Aggregates node results, which may come from several loader processes, into a result without
+// connection statistics
+func summariseNodes(config *LoadConfig, startTime, endTime time.Time, nodeResults []*NodeResult) *Result {
+	var mutationRes *MutationResult
+	if config.Mutation != nil {
+		mutationRes = newMutationResult()
+	}
+
+	latencies := make(map[string]*latencyRecorder)
+	waits := make(map[string]*latencyRecorder)
+	timeouts := make(map[string]uint)
+	var sentTransactionCount, failedTransactionCount uint
+	for _, nodeResult := range nodeResults {
+		sentTransactionCount += nodeResult.TotalTransactions
+		failedTransactionCount += nodeResult.Failed
+
+		for operation, count := range nodeResult.Timeouts {
+			timeouts[operation] += count
+		}
+
+		if mutationRes != nil && nodeResult.Mutations != nil {
+			mutationRes.merge(nodeResult.Mutations)
+		}
+
+		for scenario, recorder := range nodeResult.latencies {
+			if _, ok := latencies[scenario]; !ok {
+				latencies[scenario] = newLatencyRecorder()
+			}
+			latencies[scenario].merge(recorder)
+		}
+
+		for class, recorder := range nodeResult.waits {
+			if _, ok := waits[class]; !ok {
+				waits[class] = newLatencyRecorder()
+			}
+			waits[class].merge(recorder)
+		}
+	}
+
+	// Funds sent between loaded nodes are the inbound amounts of the receiving node
+	for _, nodeResult := range nodeResults {
+		for _, sender := range nodeResults {
+			nodeResult.Inbound += sender.OutboundByAddress[nodeResult.Address]
+		}
+	}
+
+	achievedTps := float64(sentTransactionCount) / endTime.Sub(startTime).Seconds()
 
 	res := &Result{
 		StartTime:         &startTime,
 		EndTime:           &endTime,
-		NodeCount:         uint(len(o.nodeConfigs)),
-		TotalTransactions: totalTransactionCount,
+		NodeCount:         uint(len(config.NodeConfigs)),
+		TotalTransactions: sentTransactionCount,
+		Failed:            failedTransactionCount,
+		Timeouts:          timeouts,
 		AchievedTps:       achievedTps,
+		Scenarios:         scenarioResults(latencies),
+		Nodes:             nodeResults,
+		Mutations:         mutationRes,
 	}
 
-	return res, nil
+	if len(waits) > 0 {
+		res.RateLimitWait = waitResults(waits)
+	}
+
+	return res
+}
+
+// Generates the requested receiver addresses and assigns every load client its receiver selector
+func (o *Orchestrator) prepareReceivers() error {
+	o.setPhase(phaseReceivers)
+
+	if o.config.GeneratedReceivers > 0 {
+		generatorConfig := o.nodeConfigs[o.config.ReceiverGeneratorNode]
+		generatorClient := o.millixClients[nodeAddress(generatorConfig)]
+
+		fmt.Printf("[Orchestrator][Receivers] Generating %d receiver addresses on node %s.\n", o.config.GeneratedReceivers, generatorConfig.ID)
+
+		for i := uint(0); i < o.config.GeneratedReceivers; i++ {
+			info, err := generatorClient.GenerateNewAddress()
+			if err != nil {
+				return errors.Wrap(err, "Failed to generate receiver address")
+			}
+
+			o.receivers = append(o.receivers, &receiver{addressBase: info.AddressBase, keyIdentifier: info.AddressKeyIdentifier})
+		}
+	}
+
+	if o.config.Topology == TopologyNone {
+		fmt.Printf("[Orchestrator][Receivers] Using %d receivers with %s strategy.\n", len(o.receivers), o.config.ReceiverStrategy)
+	} else {
+		fmt.Printf("[Orchestrator][Receivers] Using %s topology.\n", o.config.Topology)
+	}
+
+	for i, nodeConfig := range o.nodeConfigs {
+		loadClient := o.loadClients[nodeAddress(nodeConfig)]
+		loadClient.receivers = newTopologySelector(o.config, i, o.receivers, loadClient.rng)
+	}
+
+	return nil
 }
 
 // Ensures that all the nodes have enough funds to perform the required load test
 // The first node is assumed to have enough funds (funded in genesis)
 func (o *Orchestrator) ensureFunds() error {
+	o.setPhase(phaseFunding)
+
 	fmt.Printf("[Orchestrator][Step 1] Ensuring that all of the nodes have sufficient funds.\n")
 
 	nodeSender := o.funderClient
@@ -97,7 +312,7 @@ func (o *Orchestrator) ensureFunds() error {
 	// Skipping the first node
 	for i := 1; i < len(o.nodeConfigs); i++ {
 		nodeConfig := o.nodeConfigs[i]
-		receiverAmounts = append(receiverAmounts, &client.ReceiverAmount{AddressBase: nodeConfig.AddressBase, KeyIdentifier: nodeConfig.KeyIdentifier, Amount: o.transactionPerNode})
+		receiverAmounts = append(receiverAmounts, &client.ReceiverAmount{AddressBase: nodeConfig.AddressBase, KeyIdentifier: nodeConfig.KeyIdentifier, Amount: o.transactionPerNode * o.outputAmount})
 	}
 
 	tx, err := nodeSender.SendMillix(receiverAmounts)
@@ -160,6 +375,8 @@ type prepareOutputsRes struct {
 
 // Prepares outputs by instructing all individual load clients to prepare outputs
 func (o *Orchestrator) prepareOutputs() error {
+	o.setPhase(phaseOutputs)
+
 	fmt.Printf("[Orchestrator][Step 2] Preparing transaction outputs.\n")
 
 	resCh := make(chan *prepareOutputsRes, len(o.loadClients))
@@ -189,29 +406,93 @@ func (o *Orchestrator) prepareOutputs() error {
 	return nil
 }
 
+type prepareWorkersRes struct {
+	Address string
+	Err     error
+}
+
+// Instructs all the load clients to load their signing keys and create their worker clients before
+// the timed window
+func (o *Orchestrator) prepareWorkers() error {
+	fmt.Printf("[Orchestrator][Step 3] Preparing workers.\n")
+	resCh := make(chan *prepareWorkersRes, len(o.loadClients))
+
+	for address, loadClient := range o.loadClients {
+		go func(address string, loadClient *LoadClient) {
+			resCh <- &prepareWorkersRes{
+				Address: address,
+				Err:     loadClient.PrepareWorkers(),
+			}
+		}(address, loadClient)
+	}
+
+	for i := 0; i < len(o.loadClients); i++ {
+		res := <-resCh
+		if res.Err != nil {
+			return errors.Wrap(res.Err, fmt.Sprintf("Failed to prepare workers on node %s", res.Address))
+		}
+	}
+
+	return nil
+}
+
+type presignTransactionsRes struct {
+	Address string
+	Err     error
+}
+
+// Instructs all the load clients to sign their transactions before the timed window
+func (o *Orchestrator) presignTransactions() error {
+	o.setPhase(phasePresign)
+
+	fmt.Printf("[Orchestrator][Step 3] Pre-signing transactions.\n")
+	resCh := make(chan *presignTransactionsRes, len(o.loadClients))
+
+	for address, loadClient := range o.loadClients {
+		go func(address string, loadClient *LoadClient) {
+			err := loadClient.PresignTransactions()
+			resCh <- &presignTransactionsRes{
+				Address: address,
+				Err:     err,
+			}
+		}(address, loadClient)
+	}
+
+	for i := 0; i < len(o.loadClients); i++ {
+		res := <-resCh
+		if res.Err != nil {
+			return errors.Wrap(res.Err, fmt.Sprintf("Failed to pre-sign transactions on node %s", res.Address))
+		}
+
+		fmt.Printf("[Orchestrator][Step 3] Node %s successfully pre-signed transactions.\n", res.Address)
+	}
+
+	return nil
+}
+
 type sendTransactionsRes struct {
 	Address string
+	Result  *NodeResult
 	Err     error
 }
 
 // Instructs all the load clients to send transactions
-func (o *Orchestrator) sendTransactions() error {
+func (o *Orchestrator) sendTransactions() ([]*NodeResult, error) {
+	o.setPhase(phaseLoad)
+
 	fmt.Printf("[Orchestrator][Step 3] Sending transactions.\n")
+
+	// Connection reuse is reported for the timed window only
+	o.clients.resetStats()
+
 	resCh := make(chan *sendTransactionsRes, len(o.loadClients))
 
 	for address, loadClient := range o.loadClients {
 		go func(address string, loadClient *LoadClient) {
-			if err := loadClient.ObtainKeyMaps(); err != nil {
-				resCh <- &sendTransactionsRes{
-					Address: address,
-					Err:     err,
-				}
-				return
-			}
-
-			err := loadClient.SendTransactions()
+			nodeResult, err := loadClient.SendTransactions()
 			resCh <- &sendTransactionsRes{
 				Address: address,
+				Result:  nodeResult,
 				Err:     err,
 			}
 		}(address, loadClient)
@@ -219,16 +500,55 @@ func (o *Orchestrator) sendTransactions() error {
 
 	fmt.Printf("[Orchestrator][Step 3] Waiting for send transactions results.\n")
 
+	nodeResults := make([]*NodeResult, 0, len(o.loadClients))
+
 	for i := 0; i < len(o.loadClients); i++ {
 		res := <-resCh
 		if res.Err != nil {
-			return errors.Wrap(res.Err, fmt.Sprintf("Failed to send transactions on node %s", res.Address))
+			return nil, errors.Wrap(res.Err, fmt.Sprintf("Failed to send transactions on node %s", res.Address))
 		}
 
 		fmt.Printf("[Orchestrator][Step 3] Node %s successfully sent transactions.\n", res.Address)
+		nodeResults = append(nodeResults, res.Result)
 	}
 
 	fmt.Printf("[Orchestrator] All transactions successfully sent.\n")
 
-	return nil
+	return nodeResults, nil
+}
+
+// Instructs all the load clients to inject double spends, each conflicting with the next node in the config
+func (o *Orchestrator) injectDoubleSpends() *DoubleSpendResult {
+	o.setPhase(phaseDoubleSpend)
+
+	fmt.Printf("[Orchestrator][Step 4] Injecting double spends.\n")
+
+	resCh := make(chan *DoubleSpendResult, len(o.nodeConfigs))
+
+	for i, nodeConfig := range o.nodeConfigs {
+		loadClient := o.loadClients[nodeAddress(nodeConfig)]
+		peerClient := o.millixClients[nodeAddress(o.nodeConfigs[(i+1)%len(o.nodeConfigs)])]
+
+		go func(loadClient *LoadClient, peerClient *client.Client) {
+			resCh <- loadClient.InjectDoubleSpends(peerClient, o.config.DoubleSpend)
+		}(loadClient, peerClient)
+	}
+
+	res := newDoubleSpendResult()
+	for i := 0; i < len(o.nodeConfigs); i++ {
+		nodeRes := <-resCh
+		o.doubleSpendResults = append(o.doubleSpendResults, nodeRes)
+		res.merge(nodeRes)
+	}
+
+	fmt.Printf("[Orchestrator][Step 4] Double spends done. Attempted: %d. Conflicts detected: %d.\n", res.Attempted, res.ConflictsDetected)
+
+	return res
+}
+
+// Releases the resources of the run. The error reports exchanges that could not be recorded.
+func (o *Orchestrator) Close() error {
+	o.stopLiveness()
+
+	return o.clients.close()
 }
@@ -0,0 +1,237 @@
+package load
+
+import (
+	"fmt"
+	"millix-performance-test/client"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultPropagationSampleEvery    = 100
+	defaultPropagationTimeout        = 60
+	defaultPropagationPollIntervalMs = 500
+)
+
+type PropagationResult struct {
+	Sampled uint               `json:"sampled"`
+	Pairs   []*PropagationPair `json:"pairs"`
+}
+
+// Delays of the transactions submitted to the From node until they were listed as stable outputs of their
+// recipient on the To node
+type PropagationPair struct {
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Delay    *LatencyStats `json:"delay"`
+	Timeouts uint          `json:"timeouts"`
+}
+
+type propagationRoute struct {
+	from string
+	to   string
+}
+
+// Collects a sample of the submitted transactions and follows them until they are listed in the
+// recipient's stable outputs on every other node. Each node is polled by a single goroutine, so the probe
+// adds at most one request at a time to the load of a node.
+type propagationProbe struct {
+	config    *PropagationConfig
+	clients   map[string]*client.Client
+	submitted uint64
+	mu        sync.Mutex
+	sampled   uint
+	// Samples not listed yet, per node address
+	pending  map[string][]*propagationSample
+	delays   map[propagationRoute]*latencyRecorder
+	timeouts map[propagationRoute]uint
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type propagationSample struct {
+	origin                 string
+	transactionID          string
+	recipientKeyIdentifier string
+	submitTime             time.Time
+}
+
+func newPropagationProbe(config *PropagationConfig, clients map[string]*client.Client) *propagationProbe {
+	return &propagationProbe{
+		config:   config,
+		clients:  clients,
+		pending:  make(map[string][]*propagationSample),
+		delays:   make(map[propagationRoute]*latencyRecorder),
+		timeouts: make(map[propagationRoute]uint),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Starts polling the nodes. Must be called before the timed window.
+func (p *propagationProbe) start() {
+	for address, millixClient := range p.clients {
+		p.wg.Add(1)
+		go func(address string, millixClient *client.Client) {
+			defer p.wg.Done()
+			p.poll(address, millixClient)
+		}(address, millixClient)
+	}
+}
+
+// Called by the load client workers after every successful submission
+func (p *propagationProbe) observe(origin, transactionID, recipientKeyIdentifier string, submitTime time.Time) {
+	if (atomic.AddUint64(&p.submitted, 1)-1)%uint64(p.config.SampleEvery) != 0 {
+		return
+	}
+
+	sample := &propagationSample{
+		origin:                 origin,
+		transactionID:          transactionID,
+		recipientKeyIdentifier: recipientKeyIdentifier,
+		submitTime:             submitTime,
+	}
+
+	p.mu.Lock()
+	p.sampled++
+	for address := range p.clients {
+		if address != origin {
+			p.pending[address] = append(p.pending[address], sample)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// Polls the node every PollIntervalMs. Once stopped, it keeps polling until none of the samples is
+// pending on the node anymore.
+func (p *propagationProbe) poll(address string, millixClient *client.Client) {
+	ticker := time.NewTicker(time.Millisecond * time.Duration(p.config.PollIntervalMs))
+	defer ticker.Stop()
+
+	stop := p.stop
+	for {
+		select {
+		case <-stop:
+			stop = nil
+		case <-ticker.C:
+		}
+
+		if !p.pollNode(address, millixClient) && stop == nil {
+			return
+		}
+	}
+}
+
+// Looks the samples pending on the node up, fetching the outputs of every recipient once. The delay of
+// a sample is recorded at its first sighting, samples older than the timeout are given up. Returns whether
+// samples are still pending on the node.
+func (p *propagationProbe) pollNode(address string, millixClient *client.Client) bool {
+	p.mu.Lock()
+	samples := p.pending[address]
+	p.pending[address] = nil
+	p.mu.Unlock()
+
+	timeout := time.Second * time.Duration(p.config.TimeoutSeconds)
+	listed := make(map[string]map[string]bool)
+	seen := make(map[*propagationSample]time.Duration)
+	remaining := make([]*propagationSample, 0, len(samples))
+	expired := make([]*propagationSample, 0)
+
+	for _, sample := range samples {
+		transactionIDs, ok := listed[sample.recipientKeyIdentifier]
+		if !ok {
+			outputs, err := millixClient.GetUnspentTransactionOutputs(sample.recipientKeyIdentifier)
+			if err != nil {
+				fmt.Printf("[Propagation] Failed to get outputs from %s: %s\n", address, err)
+			}
+
+			transactionIDs = make(map[string]bool, len(outputs))
+			for _, output := range outputs {
+				transactionIDs[output.TransactionID] = true
+			}
+			listed[sample.recipientKeyIdentifier] = transactionIDs
+		}
+
+		delay := time.Since(sample.submitTime)
+		switch {
+		case transactionIDs[sample.transactionID]:
+			seen[sample] = delay
+		case delay > timeout:
+			expired = append(expired, sample)
+		default:
+			remaining = append(remaining, sample)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for sample, delay := range seen {
+		p.recordDelay(propagationRoute{from: sample.origin, to: address}, delay)
+	}
+	for _, sample := range expired {
+		p.timeouts[propagationRoute{from: sample.origin, to: address}]++
+	}
+
+	// Samples taken during this poll were added meanwhile
+	p.pending[address] = append(remaining, p.pending[address]...)
+
+	return len(p.pending[address]) > 0
+}
+
+// Must be called with the lock held
+func (p *propagationProbe) recordDelay(route propagationRoute, delay time.Duration) {
+	recorder, ok := p.delays[route]
+	if !ok {
+		recorder = newLatencyRecorder()
+		p.delays[route] = recorder
+	}
+
+	recorder.record(delay)
+}
+
+// Waits until every sampled transaction propagated or timed out. Must be called after the window.
+func (p *propagationProbe) result() *PropagationResult {
+	close(p.stop)
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	routes := make(map[propagationRoute]bool)
+	for route := range p.delays {
+		routes[route] = true
+	}
+	for route := range p.timeouts {
+		routes[route] = true
+	}
+
+	res := &PropagationResult{
+		Sampled: p.sampled,
+		Pairs:   make([]*PropagationPair, 0, len(routes)),
+	}
+
+	for route := range routes {
+		delay := newLatencyRecorder()
+		if recorder, ok := p.delays[route]; ok {
+			delay = recorder
+		}
+
+		res.Pairs = append(res.Pairs, &PropagationPair{
+			From:     route.from,
+			To:       route.to,
+			Delay:    delay.stats(),
+			Timeouts: p.timeouts[route],
+		})
+	}
+
+	sort.Slice(res.Pairs, func(x, y int) bool {
+		if res.Pairs[x].From != res.Pairs[y].From {
+			return res.Pairs[x].From < res.Pairs[y].From
+		}
+		return res.Pairs[x].To < res.Pairs[y].To
+	})
+
+	return res
+}
@@ -0,0 +1,201 @@
+package load
+
+import (
+	"github.com/pkg/errors"
+	"millix-performance-test/client"
+	"sync"
+	"time"
+)
+
+// The operation classes that are rate limited separately
+const (
+	RateSign   = "sign"
+	RateSubmit = "submit"
+	RateQuery  = "query"
+)
+
+// Global limits the requests to all the nodes together and Node the requests to every single node.
+// A node can override Node with the rate_limits of its own config.
+type RateLimitConfig struct {
+	Global *OperationRates `json:"global"`
+	Node   *OperationRates `json:"node"`
+}
+
+// Requests per second of every operation class, 0 means unlimited. Burst is the number of requests
+// that may be sent at once after an idle period and defaults to 1.
+type OperationRates struct {
+	Sign   float64 `json:"sign"`
+	Submit float64 `json:"submit"`
+	Query  float64 `json:"query"`
+	Burst  uint    `json:"burst"`
+}
+
+func (r *OperationRates) validate() error {
+	if r.Sign < 0 || r.Submit < 0 || r.Query < 0 {
+		return errors.New("Rate limits can not be negative")
+	}
+
+	if r.Burst == 0 {
+		r.Burst = 1
+	}
+
+	return nil
+}
+
+func (r *OperationRates) limited() bool {
+	return r != nil && (r.Sign > 0 || r.Submit > 0 || r.Query > 0)
+}
+
+func (r *OperationRates) buckets() map[string]*tokenBucket {
+	buckets := make(map[string]*tokenBucket)
+	if r == nil {
+		return buckets
+	}
+
+	for class, rate := range map[string]float64{RateSign: r.Sign, RateSubmit: r.Submit, RateQuery: r.Query} {
+		if rate > 0 {
+			buckets[class] = newTokenBucket(rate, r.Burst)
+		}
+	}
+
+	return buckets
+}
+
+func rateClass(operation string) string {
+	switch operation {
+	case client.OperationSign:
+		return RateSign
+	case client.OperationSubmit:
+		return RateSubmit
+	default:
+		return RateQuery
+	}
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst uint) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Takes a token and returns how long the caller has to wait until the token is available.
+// Tokens may go negative, so waiting callers are served in order.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Holds the global buckets and the buckets of every node
+type rateLimiter struct {
+	config *LoadConfig
+	global map[string]*tokenBucket
+	mu     sync.Mutex
+	nodes  map[string]*nodeLimiter
+}
+
+func newRateLimiter(config *LoadConfig) *rateLimiter {
+	var global *OperationRates
+	if config.RateLimits != nil {
+		global = config.RateLimits.Global
+	}
+
+	return &rateLimiter{
+		config: config,
+		global: global.buckets(),
+		nodes:  make(map[string]*nodeLimiter),
+	}
+}
+
+// The limiter of the node's clients, or nil when the node is not limited at all
+func (l *rateLimiter) node(nodeConfig *NodeConfig) client.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.nodes[nodeConfig.ID]
+	if !ok {
+		rates := nodeConfig.RateLimits
+		if rates == nil && l.config.RateLimits != nil {
+			rates = l.config.RateLimits.Node
+		}
+
+		limiter = &nodeLimiter{
+			global: l.global,
+			node:   rates.buckets(),
+		}
+		l.nodes[nodeConfig.ID] = limiter
+	}
+
+	if len(limiter.global) == 0 && len(limiter.node) == 0 {
+		return nil
+	}
+
+	return limiter
+}
+
+// Reports whether any request of the run is rate limited
+func (l *rateLimiter) limited() bool {
+	if len(l.global) > 0 {
+		return true
+	}
+
+	if l.config.RateLimits != nil && l.config.RateLimits.Node.limited() {
+		return true
+	}
+
+	for _, nodeConfig := range l.config.allNodeConfigs() {
+		if nodeConfig.RateLimits.limited() {
+			return true
+		}
+	}
+
+	return false
+}
+
+type nodeLimiter struct {
+	global map[string]*tokenBucket
+	node   map[string]*tokenBucket
+}
+
+func (l *nodeLimiter) Wait(operation string) time.Duration {
+	class := rateClass(operation)
+
+	var wait time.Duration
+	for _, buckets := range []map[string]*tokenBucket{l.node, l.global} {
+		if bucket, ok := buckets[class]; ok {
+			if bucketWait := bucket.reserve(); bucketWait > wait {
+				wait = bucketWait
+			}
+		}
+	}
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	return wait
+}
@@ -0,0 +1,121 @@
+package load
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+const (
+	// Every node pays the configured receiver pool
+	TopologyNone = ""
+	// Every node pays the next node in the config
+	TopologyRing = "ring"
+	// Every node pays all other nodes in round-robin order
+	TopologyMesh = "mesh"
+	// Every node pays a randomly chosen other node
+	TopologyRandom = "random"
+
+	// Cycles through the receivers in order
+	StrategyRoundRobin = "round_robin"
+	// Picks receivers uniformly at random
+	StrategyRandom = "random"
+	// Picks receivers following a zipf distribution, the first receivers being the hottest
+	StrategyZipf = "zipf"
+
+	defaultZipfExponent = 1.1
+)
+
+type receiver struct {
+	addressBase   string
+	keyIdentifier string
+}
+
+func (r *receiver) address() string {
+	return fmt.Sprintf("%slal%s", r.addressBase, r.keyIdentifier)
+}
+
+// Chooses the receiver of each load transaction output.
+// Selectors are owned by a single load client, so they need no locking.
+type receiverSelector interface {
+	next() *receiver
+}
+
+type roundRobinSelector struct {
+	receivers []*receiver
+	position  int
+}
+
+func (s *roundRobinSelector) next() *receiver {
+	r := s.receivers[s.position]
+	s.position = (s.position + 1) % len(s.receivers)
+
+	return r
+}
+
+type randomSelector struct {
+	receivers []*receiver
+	rng       *rand.Rand
+}
+
+func (s *randomSelector) next() *receiver {
+	return s.receivers[s.rng.Intn(len(s.receivers))]
+}
+
+type zipfSelector struct {
+	receivers []*receiver
+	zipf      *rand.Zipf
+}
+
+func (s *zipfSelector) next() *receiver {
+	return s.receivers[s.zipf.Uint64()]
+}
+
+func newReceiverSelector(strategy string, receivers []*receiver, offset int, zipfExponent float64, rng *rand.Rand) receiverSelector {
+	switch strategy {
+	case StrategyRandom:
+		return &randomSelector{receivers: receivers, rng: rng}
+	case StrategyZipf:
+		return &zipfSelector{receivers: receivers, zipf: rand.NewZipf(rng, zipfExponent, 1, uint64(len(receivers)-1))}
+	default:
+		return &roundRobinSelector{receivers: receivers, position: offset % len(receivers)}
+	}
+}
+
+// Builds the receiver selector of the node at the given index of the config.
+// The pool is used when the config has no topology.
+func newTopologySelector(config *LoadConfig, nodeIndex int, pool []*receiver, rng *rand.Rand) receiverSelector {
+	if config.Topology == TopologyNone {
+		return newReceiverSelector(config.ReceiverStrategy, pool, nodeIndex, config.ZipfExponent, rng)
+	}
+
+	// Peers are ordered starting from the next node so that the ring and the mesh start at the same peer
+	peers := make([]*receiver, 0, len(config.NodeConfigs)-1)
+	for i := 1; i < len(config.NodeConfigs); i++ {
+		peer := config.NodeConfigs[(nodeIndex+i)%len(config.NodeConfigs)]
+		peers = append(peers, &receiver{addressBase: peer.AddressBase, keyIdentifier: peer.KeyIdentifier})
+	}
+
+	switch config.Topology {
+	case TopologyRing:
+		return &roundRobinSelector{receivers: peers[:1]}
+	case TopologyRandom:
+		return &randomSelector{receivers: peers, rng: rng}
+	default:
+		return &roundRobinSelector{receivers: peers}
+	}
+}
+
+// The receivers listed in the config, including the single legacy receiver
+func configuredReceivers(config *LoadConfig) []*receiver {
+	receivers := make([]*receiver, 0, len(config.Receivers)+1)
+
+	if config.ReceiverAddressBase != "" {
+		receivers = append(receivers, &receiver{addressBase: config.ReceiverAddressBase, keyIdentifier: config.ReceiverKeyIdentifier})
+	}
+
+	for _, receiverConfig := range config.Receivers {
+		receivers = append(receivers, &receiver{addressBase: receiverConfig.AddressBase, keyIdentifier: receiverConfig.KeyIdentifier})
+	}
+
+	return receivers
+}
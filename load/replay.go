@@ -0,0 +1,160 @@
+package load
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"millix-performance-test/client"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Every request to a node and its response are written to Path as JSON lines
+type RecordingConfig struct {
+	Path string `json:"path"`
+}
+
+type ReplayResult struct {
+	StartTime     *time.Time               `json:"start_time"`
+	EndTime       *time.Time               `json:"end_time"`
+	Exchanges     uint                     `json:"exchange_count"`
+	Failed        uint                     `json:"failed_count"`
+	StatusMatched uint                     `json:"status_matched_count"`
+	StatusChanged []*ReplayDifference      `json:"status_changed"`
+	Operations    map[string]*LatencyStats `json:"operations"`
+}
+
+// A replayed exchange whose status differs from the recording. Status 0 means no response.
+type ReplayDifference struct {
+	Index          int    `json:"index"`
+	Operation      string `json:"operation"`
+	RouteID        string `json:"route_id"`
+	RecordedStatus int    `json:"recorded_status"`
+	Status         int    `json:"status"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Re-issues the exchanges of a recording to the configured nodes, matched by node id, or to the node
+// with nodeID when it is set. With originalTiming every exchange starts at its recorded offset,
+// otherwise the exchanges are sent one after the other as fast as possible.
+func Replay(config *LoadConfig, path string, nodeID string, originalTiming bool) (*ReplayResult, error) {
+	if config.Recording != nil && config.Recording.Path == path {
+		return nil, errors.New("The replayed recording can not be the recording path of the config")
+	}
+
+	exchanges, err := client.ReadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exchanges are recorded when they complete, they are replayed in the order they started
+	sort.SliceStable(exchanges, func(x, y int) bool {
+		return exchanges[x].OffsetMs < exchanges[y].OffsetMs
+	})
+
+	nodeConfigs := make(map[string]*NodeConfig)
+	for _, nodeConfig := range config.allNodeConfigs() {
+		nodeConfigs[nodeConfig.ID] = nodeConfig
+	}
+
+	clients := newClientFactory(config)
+	millixClients := make(map[string]*client.Client)
+	targets := make([]*client.Client, len(exchanges))
+	for i, exchange := range exchanges {
+		target := exchange.NodeID
+		if nodeID != "" {
+			target = nodeID
+		}
+
+		nodeConfig, ok := nodeConfigs[target]
+		if !ok {
+			return nil, fmt.Errorf("Node %s of exchange %d is not configured", target, i+1)
+		}
+		if _, ok := millixClients[target]; !ok {
+			millixClients[target] = clients.client(nodeConfig)
+		}
+		targets[i] = millixClients[target]
+	}
+
+	fmt.Printf("[Replay] Replaying %d exchanges from %s.\n", len(exchanges), path)
+
+	res := &ReplayResult{
+		StatusChanged: make([]*ReplayDifference, 0),
+	}
+	var mu sync.Mutex
+	latencies := make(map[string]*latencyRecorder)
+
+	replay := func(i int) {
+		exchange := exchanges[i]
+		start := time.Now()
+		status, err := targets[i].Replay(exchange)
+		latency := time.Since(start)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		res.Exchanges++
+		if _, ok := latencies[exchange.Operation]; !ok {
+			latencies[exchange.Operation] = newLatencyRecorder()
+		}
+		latencies[exchange.Operation].record(latency)
+
+		if err != nil {
+			res.Failed++
+		}
+		if status == exchange.Status {
+			res.StatusMatched++
+			return
+		}
+
+		difference := &ReplayDifference{
+			Index:          i,
+			Operation:      exchange.Operation,
+			RouteID:        exchange.RouteID,
+			RecordedStatus: exchange.Status,
+			Status:         status,
+		}
+		if err != nil {
+			difference.Error = err.Error()
+		}
+		res.StatusChanged = append(res.StatusChanged, difference)
+	}
+
+	startTime := time.Now()
+
+	if originalTiming && len(exchanges) > 0 {
+		var wg sync.WaitGroup
+		firstOffset := exchanges[0].OffsetMs
+		for i, exchange := range exchanges {
+			offset := time.Duration((exchange.OffsetMs - firstOffset) * float64(time.Millisecond))
+			time.Sleep(time.Until(startTime.Add(offset)))
+
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				replay(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range exchanges {
+			replay(i)
+		}
+	}
+
+	endTime := time.Now()
+	res.StartTime = &startTime
+	res.EndTime = &endTime
+	res.Operations = make(map[string]*LatencyStats)
+	for operation, recorder := range latencies {
+		res.Operations[operation] = recorder.stats()
+	}
+
+	fmt.Printf("[Replay] Replayed %d exchanges. %d failed. %d changed status.\n", res.Exchanges, res.Failed, len(res.StatusChanged))
+
+	if err := clients.close(); err != nil {
+		return nil, errors.Wrap(err, "Failed to record the replay")
+	}
+
+	return res, nil
+}
@@ -1,11 +1,105 @@
 package load
 
-import "time"
+import (
+	"millix-performance-test/client"
+	"time"
+)
 
 type Result struct {
-	StartTime         *time.Time `json:"start_time"`
-	EndTime           *time.Time `json:"end_time"`
-	TotalTransactions uint       `json:"total_transaction_count"`
-	NodeCount         uint       `json:"node_count"`
-	AchievedTps       float64    `json:"achieved_tps"`
+	Metadata          *Metadata                          `json:"metadata,omitempty"`
+	StartTime         *time.Time                         `json:"start_time"`
+	EndTime           *time.Time                         `json:"end_time"`
+	TotalTransactions uint                               `json:"total_transaction_count"`
+	Failed            uint                               `json:"failed_transaction_count"`
+	Timeouts          map[string]uint                    `json:"timeouts"`
+	NodeCount         uint                               `json:"node_count"`
+	AchievedTps       float64                            `json:"achieved_tps"`
+	Scenarios         map[string]*ScenarioResult         `json:"scenarios"`
+	Nodes             []*NodeResult                      `json:"nodes"`
+	DoubleSpend       *DoubleSpendResult                 `json:"double_spend,omitempty"`
+	Mutations         *MutationResult                    `json:"mutations,omitempty"`
+	Verification      *VerificationResult                `json:"verification,omitempty"`
+	Propagation       *PropagationResult                 `json:"propagation,omitempty"`
+	Connections       map[string]*client.ConnectionStats `json:"connections"`
+	RateLimitWait     map[string]*LatencyStats           `json:"rate_limit_wait,omitempty"`
+	Intervals         []*IntervalResult                  `json:"intervals,omitempty"`
+	Assertions        *AssertionResult                   `json:"assertions,omitempty"`
+	Liveness          []*NodeLiveness                    `json:"liveness,omitempty"`
+}
+
+type ScenarioResult struct {
+	Count   uint          `json:"count"`
+	Latency *LatencyStats `json:"latency"`
+}
+
+// Throughput of the signing phase when transactions are signed before the timed window
+type SigningResult struct {
+	TotalTransactions uint    `json:"total_transaction_count"`
+	Failed            uint    `json:"failed_count"`
+	DurationSeconds   float64 `json:"duration_seconds"`
+	AchievedTps       float64 `json:"achieved_tps"`
+}
+
+type NodeResult struct {
+	Address           string                     `json:"address"`
+	TotalTransactions uint                       `json:"total_transaction_count"`
+	Failed            uint                       `json:"failed_transaction_count"`
+	Timeouts          map[string]uint            `json:"timeouts"`
+	AchievedTps       float64                    `json:"achieved_tps"`
+	Scenarios         map[string]*ScenarioResult `json:"scenarios"`
+	SubmitLatency     *LatencyStats              `json:"submit_latency"`
+	Inbound           uint                       `json:"inbound_amount"`
+	Outbound          uint                       `json:"outbound_amount"`
+	OutboundByAddress map[string]uint            `json:"outbound_by_address"`
+	Mutations         *MutationResult            `json:"mutations,omitempty"`
+	Signing           *SigningResult             `json:"signing,omitempty"`
+	SignNodes         []string                   `json:"sign_nodes,omitempty"`
+	SubmitNodes       []string                   `json:"submit_nodes,omitempty"`
+	RateLimitWait     map[string]*LatencyStats   `json:"rate_limit_wait,omitempty"`
+	Recycled          uint                       `json:"recycled_output_count,omitempty"`
+	StarvedSeconds    float64                    `json:"starved_seconds,omitempty"`
+
+	latencies map[string]*latencyRecorder
+	waits     map[string]*latencyRecorder
+}
+
+// Summarises the latency recorders per scenario
+func scenarioResults(latencies map[string]*latencyRecorder) map[string]*ScenarioResult {
+	scenarios := make(map[string]*ScenarioResult)
+	for scenario, recorder := range latencies {
+		stats := recorder.stats()
+		scenarios[scenario] = &ScenarioResult{
+			Count:   stats.Count,
+			Latency: stats,
+		}
+	}
+
+	return scenarios
+}
+
+// Summarises the time spent waiting for the rate limiters per operation class
+func waitResults(waits map[string]*latencyRecorder) map[string]*LatencyStats {
+	stats := make(map[string]*LatencyStats)
+	for class, recorder := range waits {
+		stats[class] = recorder.stats()
+	}
+
+	return stats
+}
+
+// Share of the transactions that failed
+func (r *Result) ErrorRate() float64 {
+	return errorRate(r.TotalTransactions, r.Failed)
+}
+
+func (r *NodeResult) ErrorRate() float64 {
+	return errorRate(r.TotalTransactions, r.Failed)
+}
+
+func errorRate(sent, failed uint) float64 {
+	if sent+failed == 0 {
+		return 0
+	}
+
+	return float64(failed) / float64(sent+failed)
 }
@@ -0,0 +1,92 @@
+package load
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+// Routes the signing and the submission of the load transactions to other nodes than the one that owns
+// the funds. SignNodes and SubmitNodes hold node ids of the loaded nodes or of the extra Nodes, which are
+// only used for routing and are not loaded themselves. An empty list keeps the owning node.
+type RoutingConfig struct {
+	Nodes       []*NodeConfig `json:"nodes"`
+	SignNodes   []string      `json:"sign_nodes"`
+	SubmitNodes []string      `json:"submit_nodes"`
+}
+
+func (c *RoutingConfig) validate(config *LoadConfig) error {
+	for _, nodeConfig := range c.Nodes {
+		if nodeConfig.IP == "" || nodeConfig.Port == "" || nodeConfig.ID == "" || nodeConfig.Signature == "" {
+			return errors.New("Routing nodes need an ip, port, id and signature")
+		}
+	}
+
+	if len(c.SignNodes) > 0 && config.Signer == SignerLocal {
+		return errors.New("sign_nodes can not be used with the local signer")
+	}
+
+	for _, ids := range [][]string{c.SignNodes, c.SubmitNodes} {
+		if _, err := c.resolve(config, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The loaded nodes followed by the nodes that are only used for routing
+func (c *LoadConfig) allNodeConfigs() []*NodeConfig {
+	if c.Routing == nil {
+		return c.NodeConfigs
+	}
+
+	return append(append([]*NodeConfig{}, c.NodeConfigs...), c.Routing.Nodes...)
+}
+
+// Looks up the configs of the node ids among the loaded and the routing nodes
+func (c *RoutingConfig) resolve(config *LoadConfig, ids []string) ([]*NodeConfig, error) {
+	nodeConfigs := make([]*NodeConfig, 0, len(ids))
+
+Outer:
+	for _, id := range ids {
+		for _, nodeConfig := range config.allNodeConfigs() {
+			if nodeConfig.ID == id {
+				nodeConfigs = append(nodeConfigs, nodeConfig)
+				continue Outer
+			}
+		}
+
+		return nil, fmt.Errorf("Routing node %s is not configured", id)
+	}
+
+	return nodeConfigs, nil
+}
+
+// The nodes a load client signs and submits on. Without routing both are the load client's own node.
+func routedNodes(config *LoadConfig, nodeConfig *NodeConfig) ([]*NodeConfig, []*NodeConfig) {
+	signNodes := []*NodeConfig{nodeConfig}
+	submitNodes := []*NodeConfig{nodeConfig}
+
+	if config.Routing == nil {
+		return signNodes, submitNodes
+	}
+
+	// Validate has already checked that all the ids resolve
+	if len(config.Routing.SignNodes) > 0 {
+		signNodes, _ = config.Routing.resolve(config, config.Routing.SignNodes)
+	}
+	if len(config.Routing.SubmitNodes) > 0 {
+		submitNodes, _ = config.Routing.resolve(config, config.Routing.SubmitNodes)
+	}
+
+	return signNodes, submitNodes
+}
+
+func nodeIDs(nodeConfigs []*NodeConfig) []string {
+	ids := make([]string, 0, len(nodeConfigs))
+	for _, nodeConfig := range nodeConfigs {
+		ids = append(ids, nodeConfig.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,134 @@
+package load
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"millix-performance-test/client"
+	"reflect"
+)
+
+const (
+	// Transactions are signed by the node's sign endpoint
+	SignerNode = "node"
+	// Transactions are signed in process
+	SignerLocal = "local"
+)
+
+// A node-signed transaction and the key map it was signed with, as read by the signer tests
+type signerFixture struct {
+	Transaction *client.Transaction `json:"transaction"`
+	KeyMap      map[string]string   `json:"key_map"`
+}
+
+// Signs a payment of the first node to itself with the node signer, without submitting it, and checks
+// that the local signer reproduces the payload hash, the signatures and the transaction id. With a
+// fixturePath the node-signed transaction and its key map are written there, also when they differ.
+func VerifySigner(config *LoadConfig, fixturePath string) error {
+	nodeConfig := config.NodeConfigs[0]
+	address := nodeAddress(nodeConfig)
+	clients := newClientFactory(config)
+	defer clients.close()
+	millixClient := clients.client(nodeConfig)
+
+	keyMap, publicKeyMap, err := loadKeyMaps(millixClient, address)
+	if err != nil {
+		return err
+	}
+
+	for addressBase, publicKey := range publicKeyMap {
+		if err := client.VerifyKeyPair(keyMap[addressBase], publicKey); err != nil {
+			return errors.Wrap(err, "Local key derivation does not match the node")
+		}
+	}
+
+	outputs, err := millixClient.GetUnspentTransactionOutputs(nodeConfig.KeyIdentifier)
+	if err != nil {
+		return errors.Wrap(err, "Failed to get outputs")
+	}
+
+	if len(outputs) == 0 {
+		return errors.New("No outputs")
+	}
+
+	output := outputs[0]
+	unsignedTx := &client.UnsignedTransaction{
+		TransactionVersion: "la0l",
+		InputList: []*client.TransactionInput{{
+			AddressBase:           nodeConfig.KeyIdentifier,
+			AddressKeyIdentifier:  nodeConfig.KeyIdentifier,
+			AddressVersion:        "lal",
+			OutputPosition:        output.OutputPosition,
+			OutputShardID:         output.ShardID,
+			OutputTransactionDate: output.TransactionDate,
+			OutputTransactionID:   output.TransactionID,
+		}},
+		OutputList: []*client.TransactionOutput{{
+			AddressBase:          nodeConfig.AddressBase,
+			AddressVersion:       "lal",
+			AddressKeyIdentifier: nodeConfig.KeyIdentifier,
+			Amount:               output.Amount,
+		}},
+	}
+
+	nodeTx, err := millixClient.SignTransaction(unsignedTx, keyMap, publicKeyMap)
+	if err != nil {
+		return errors.Wrap(err, "Failed to sign with the node")
+	}
+
+	if fixturePath != "" {
+		content, err := json.MarshalIndent(&signerFixture{Transaction: nodeTx, KeyMap: keyMap}, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "Failed to marshal fixture")
+		}
+		if err := ioutil.WriteFile(fixturePath, content, 0600); err != nil {
+			return errors.Wrap(err, "Failed to write fixture")
+		}
+		fmt.Printf("[Signer] Node-signed transaction written to %s.\n", fixturePath)
+	}
+
+	localTx, err := client.NewLocalSigner(nodeConfig.ID).Resign(nodeTx, keyMap)
+	if err != nil {
+		return errors.Wrap(err, "Failed to sign locally")
+	}
+
+	fmt.Printf("[Signer] Payload hash. Node: %s. Local: %s.\n", nodeTx.PayloadHash, localTx.PayloadHash)
+	fmt.Printf("[Signer] Transaction id. Node: %s. Local: %s.\n", nodeTx.TransactionID, localTx.TransactionID)
+
+	if nodeTx.PayloadHash != localTx.PayloadHash {
+		return errors.New("Payload hash mismatch")
+	}
+
+	if !reflect.DeepEqual(nodeTx.SignatureList, localTx.SignatureList) {
+		return fmt.Errorf("Signature mismatch. Node: %v. Local: %v", nodeTx.SignatureList, localTx.SignatureList)
+	}
+
+	if nodeTx.TransactionID != localTx.TransactionID {
+		return errors.New("Transaction id mismatch")
+	}
+
+	fmt.Printf("[Signer] Local signer matches the node signer.\n")
+
+	return nil
+}
+
+// Fetches the private and the public key of the address. Both maps are keyed by the address base,
+// which is what the signature list of a transaction refers to, so the node and the local signer
+// receive the same maps.
+func loadKeyMaps(millixClient *client.Client, address string) (map[string]string, map[string]string, error) {
+	privateKey, err := millixClient.GetPrivateKey(address)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to get private key")
+	}
+
+	info, err := millixClient.GetAddressInfo(address)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to get address info")
+	}
+
+	keyMap := map[string]string{info.AddressBase: privateKey}
+	publicKeyMap := map[string]string{info.AddressBase: info.AddressAttribute["key_public"]}
+
+	return keyMap, publicKeyMap, nil
+}
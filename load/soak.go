@@ -0,0 +1,415 @@
+package load
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"millix-performance-test/client"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSoakIntervalSeconds  = 60
+	defaultSoakReplenishSeconds = 30
+	// Latency samples kept per recorder during a soak
+	soakLatencySampleLimit = 100000
+	// Recycled outputs are remembered at least this long, so an output that is briefly missing from
+	// the stable outputs of its node is not recycled twice
+	recycleRetention = 10 * time.Minute
+)
+
+// Runs the load for DurationSeconds of wall-clock time. The outputs that the nodes receive from each other
+// are recycled as new inputs every ReplenishIntervalSeconds, and throughput and latency are reported for
+// every IntervalSeconds. TargetTps caps the total submission rate, 0 submits at full speed.
+type SoakConfig struct {
+	DurationSeconds          uint    `json:"duration_seconds"`
+	IntervalSeconds          uint    `json:"interval_seconds"`
+	ReplenishIntervalSeconds uint    `json:"replenish_interval_seconds"`
+	TargetTps                float64 `json:"target_tps"`
+}
+
+type IntervalResult struct {
+	Index             int           `json:"index"`
+	StartSeconds      float64       `json:"start_seconds"`
+	TotalTransactions uint          `json:"total_transaction_count"`
+	Failed            uint          `json:"failed_transaction_count"`
+	AchievedTps       float64       `json:"achieved_tps"`
+	Latency           *LatencyStats `json:"latency"`
+}
+
+func (c *SoakConfig) validate(config *LoadConfig) error {
+	if c.DurationSeconds == 0 {
+		return errors.New("Soak duration_seconds must be greater than 0")
+	}
+
+	if config.Topology == TopologyNone {
+		return errors.New("Soak mode recycles the funds the nodes send each other and needs a topology")
+	}
+
+	if c.IntervalSeconds == 0 {
+		c.IntervalSeconds = defaultSoakIntervalSeconds
+	}
+	if c.ReplenishIntervalSeconds == 0 {
+		c.ReplenishIntervalSeconds = defaultSoakReplenishSeconds
+	}
+
+	return nil
+}
+
+// Funds the nodes, prepares their outputs and keeps them sending transactions until the soak duration
+// has passed
+func (o *Orchestrator) Soak() (*Result, error) {
+	config := o.config.Soak
+	if config == nil {
+		return nil, errors.New("No soak block configured")
+	}
+
+	metadata, err := o.collectMetadata()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to collect run metadata")
+	}
+
+	o.startLiveness(phaseReceivers)
+	defer o.stopLiveness()
+
+	if err := o.prepareReceivers(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare receivers")
+	}
+
+	if err := o.ensureFunds(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare initial funds")
+	}
+
+	if err := o.prepareOutputs(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare transaction outputs")
+	}
+
+	if err := o.prepareWorkers(); err != nil {
+		return nil, err
+	}
+
+	for _, loadClient := range o.loadClients {
+		recycler, err := newRecycler(loadClient)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to prepare the recycler of %s", loadClient.address)
+		}
+		loadClient.recycler = recycler
+	}
+
+	startTime := time.Now()
+	deadline := startTime.Add(time.Second * time.Duration(config.DurationSeconds))
+	intervals := newIntervalRecorder(startTime, time.Second*time.Duration(config.IntervalSeconds))
+
+	fmt.Printf("[Orchestrator][Soak] Running until %v.\n", deadline)
+
+	wg := sync.WaitGroup{}
+	for _, loadClient := range o.loadClients {
+		loadClient.soakUntil = deadline
+		loadClient.intervals = intervals
+		loadClient.latencySampleLimit = soakLatencySampleLimit
+		loadClient.submitRate = config.TargetTps / float64(len(o.loadClients))
+
+		wg.Add(1)
+		go func(loadClient *LoadClient) {
+			defer wg.Done()
+			loadClient.replenish(deadline, time.Second*time.Duration(config.ReplenishIntervalSeconds))
+		}(loadClient)
+	}
+
+	nodeResults, err := o.sendTransactions()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to perform soak")
+	}
+
+	endTime := time.Now()
+	wg.Wait()
+	o.confirmMutations(nodeResults)
+
+	for _, nodeResult := range nodeResults {
+		nodeResult.Recycled = o.loadClients[nodeResult.Address].recycler.count()
+	}
+
+	res := o.summarise(startTime, endTime, nodeResults)
+	res.Metadata = metadata
+	res.Intervals = intervals.result(endTime)
+	res.Liveness = o.stopLiveness()
+
+	return res, nil
+}
+
+// Submits transactions until the soak deadline, waiting for recycled inputs whenever the prepared
+// inputs run out
+func (lc *LoadClient) soak() *NodeResult {
+	var starved time.Duration
+
+	next := func() *pendingTransaction {
+		for {
+			if pendingTx := lc.nextTransaction(); pendingTx != nil {
+				return pendingTx
+			}
+
+			remaining := time.Until(lc.soakUntil)
+			if remaining <= 0 {
+				return nil
+			}
+			if remaining > time.Second {
+				remaining = time.Second
+			}
+
+			time.Sleep(remaining)
+			starved += remaining
+		}
+	}
+
+	// Stops the feed at the deadline even while inputs are left
+	feed := func() *pendingTransaction {
+		if !time.Now().Before(lc.soakUntil) {
+			return nil
+		}
+
+		return next()
+	}
+
+	res := lc.submitTransactions(nil, feed)
+	res.StarvedSeconds = starved.Seconds()
+
+	return res
+}
+
+// Recycles the node's received outputs every interval until the deadline
+func (lc *LoadClient) replenish(deadline time.Time, interval time.Duration) {
+	for time.Until(deadline) > interval {
+		time.Sleep(interval)
+
+		recycled, err := lc.recycler.recycle()
+		if err != nil {
+			fmt.Printf("[Load Client] Failed to recycle outputs of %s: %s\n", lc.address, err)
+			continue
+		}
+
+		fmt.Printf("[Load Client] Recycled %d outputs of %s.\n", recycled, lc.address)
+	}
+}
+
+// Turns the stable unspent outputs of a node into prepared inputs, each output once
+type recycler struct {
+	mu       sync.Mutex
+	lc       *LoadClient
+	seen     map[string]time.Time
+	recycled uint
+}
+
+// Only outputs the node receives during the soak are recycled. Every output present at the start,
+// such as the remaining balance or genesis funds, stays untouched, and so do the change outputs of
+// the prepared transactions, which may not be listed yet.
+func newRecycler(lc *LoadClient) (*recycler, error) {
+	r := &recycler{
+		lc:   lc,
+		seen: make(map[string]time.Time),
+	}
+
+	outputs, err := lc.millixClient.GetUnspentTransactionOutputs(lc.keyIdentifier)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get outputs")
+	}
+
+	now := time.Now()
+	for _, output := range outputs {
+		r.seen[outputKey(output.TransactionID, output.OutputPosition)] = now
+	}
+	for _, transaction := range lc.preparedTransactions {
+		r.seen[outputKey(transaction.TransactionID, 0)] = now
+	}
+
+	// The prepared inputs are already in use
+	for _, inputs := range [][]*preparedInput{lc.inputs, lc.doubleSpendInputs} {
+		for _, prepared := range inputs {
+			r.seen[outputKey(prepared.input.OutputTransactionID, prepared.input.OutputPosition)] = now
+		}
+	}
+
+	return r, nil
+}
+
+func outputKey(transactionID string, position uint) string {
+	return fmt.Sprintf("%s:%d", transactionID, position)
+}
+
+func (r *recycler) recycle() (uint, error) {
+	outputs, err := r.lc.millixClient.GetUnspentTransactionOutputs(r.lc.keyIdentifier)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+
+	now := time.Now()
+	current := make(map[string]bool, len(outputs))
+	fresh := make([]*preparedInput, 0)
+
+	for _, output := range outputs {
+		key := outputKey(output.TransactionID, output.OutputPosition)
+		current[key] = true
+
+		if _, ok := r.seen[key]; ok {
+			continue
+		}
+		r.seen[key] = now
+
+		fresh = append(fresh, &preparedInput{
+			input: &client.TransactionInput{
+				AddressBase:           output.AddressBase,
+				AddressKeyIdentifier:  output.AddressKeyIdentifier,
+				AddressVersion:        "lal",
+				OutputPosition:        output.OutputPosition,
+				OutputShardID:         output.ShardID,
+				OutputTransactionDate: output.TransactionDate,
+				OutputTransactionID:   output.TransactionID,
+			},
+			amount: output.Amount,
+		})
+	}
+
+	// Spent outputs never come back, so they can be forgotten
+	for key, seenTime := range r.seen {
+		if !current[key] && now.Sub(seenTime) > recycleRetention {
+			delete(r.seen, key)
+		}
+	}
+
+	r.recycled += uint(len(fresh))
+	r.mu.Unlock()
+
+	r.lc.inputsMu.Lock()
+	r.lc.inputs = append(r.lc.inputs, fresh...)
+	r.lc.inputsMu.Unlock()
+
+	return uint(len(fresh)), nil
+}
+
+func (r *recycler) count() uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.recycled
+}
+
+// Buckets the submissions of all the load clients into fixed intervals. Finished intervals are
+// summarised and printed as soon as a later interval is reached.
+type intervalRecorder struct {
+	mu       sync.Mutex
+	start    time.Time
+	interval time.Duration
+	open     map[int]*intervalBucket
+	closed   map[int]*IntervalResult
+}
+
+type intervalBucket struct {
+	count     uint
+	failed    uint
+	latencies *latencyRecorder
+}
+
+func newIntervalRecorder(start time.Time, interval time.Duration) *intervalRecorder {
+	return &intervalRecorder{
+		start:    start,
+		interval: interval,
+		open:     make(map[int]*intervalBucket),
+		closed:   make(map[int]*IntervalResult),
+	}
+}
+
+func (r *intervalRecorder) record(latency time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	bucket := r.current()
+	bucket.count++
+	bucket.latencies.record(latency)
+	r.mu.Unlock()
+}
+
+func (r *intervalRecorder) fail() {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.current().failed++
+	r.mu.Unlock()
+}
+
+// Must be called with the lock held
+func (r *intervalRecorder) current() *intervalBucket {
+	index := int(time.Since(r.start) / r.interval)
+
+	for openIndex := range r.open {
+		if openIndex < index {
+			r.close(openIndex, r.interval)
+		}
+	}
+
+	bucket, ok := r.open[index]
+	if !ok {
+		bucket = &intervalBucket{latencies: newSampledLatencyRecorder(soakLatencySampleLimit)}
+		r.open[index] = bucket
+	}
+
+	return bucket
+}
+
+func (r *intervalRecorder) close(index int, duration time.Duration) {
+	bucket := r.open[index]
+	delete(r.open, index)
+
+	res := &IntervalResult{
+		Index:             index,
+		StartSeconds:      (time.Duration(index) * r.interval).Seconds(),
+		TotalTransactions: bucket.count,
+		Failed:            bucket.failed,
+		Latency:           bucket.latencies.stats(),
+	}
+	if duration > 0 {
+		res.AchievedTps = float64(bucket.count) / duration.Seconds()
+	}
+	r.closed[index] = res
+
+	fmt.Printf("[Orchestrator][Soak] Interval %d. Tx/s: %.1f. Failed: %d. p99: %.1f ms.\n", index, res.AchievedTps, res.Failed, res.Latency.P99Ms)
+}
+
+// Closes the remaining intervals and lists all of them, including the ones without any submission
+func (r *intervalRecorder) result(endTime time.Time) []*IntervalResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := int(endTime.Sub(r.start) / r.interval)
+	for index := range r.open {
+		duration := r.interval
+		if index == last {
+			duration = endTime.Sub(r.start.Add(time.Duration(index) * r.interval))
+		}
+		r.close(index, duration)
+	}
+
+	results := make([]*IntervalResult, 0, len(r.closed))
+	for index := 0; index <= last; index++ {
+		res, ok := r.closed[index]
+		if !ok {
+			res = &IntervalResult{
+				Index:        index,
+				StartSeconds: (time.Duration(index) * r.interval).Seconds(),
+				Latency:      &LatencyStats{},
+			}
+		}
+		results = append(results, res)
+	}
+
+	sort.Slice(results, func(x, y int) bool {
+		return results[x].Index < results[y].Index
+	})
+
+	return results
+}
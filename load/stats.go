@@ -0,0 +1,186 @@
+package load
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+type LatencyStats struct {
+	Count  uint    `json:"count"`
+	MinMs  float64 `json:"min_ms"`
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P90Ms  float64 `json:"p90_ms"`
+	P99Ms  float64 `json:"p99_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// Collects latency samples from concurrent workers. A recorder with a limit keeps a uniform random
+// sample of at most limit latencies, so long runs have bounded memory and approximate percentiles.
+type latencyRecorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	count   uint
+	limit   int
+	rng     *rand.Rand
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{
+		samples: make([]time.Duration, 0),
+	}
+}
+
+func newSampledLatencyRecorder(limit int) *latencyRecorder {
+	return &latencyRecorder{
+		samples: make([]time.Duration, 0),
+		limit:   limit,
+		rng:     rand.New(rand.NewSource(1)),
+	}
+}
+
+func (r *latencyRecorder) record(d time.Duration) {
+	r.mu.Lock()
+	r.count++
+	r.add(d)
+	r.mu.Unlock()
+}
+
+// Reservoir sampling once the limit is reached. Must be called with the lock held.
+func (r *latencyRecorder) add(d time.Duration) {
+	if r.limit == 0 || len(r.samples) < r.limit {
+		r.samples = append(r.samples, d)
+		return
+	}
+
+	if j := r.rng.Int63n(int64(r.count)); j < int64(r.limit) {
+		r.samples[j] = d
+	}
+}
+
+func (r *latencyRecorder) merge(other *latencyRecorder) {
+	other.mu.Lock()
+	samples := append([]time.Duration(nil), other.samples...)
+	count := other.count
+	other.mu.Unlock()
+
+	r.mu.Lock()
+	for _, sample := range samples {
+		r.count++
+		r.add(sample)
+	}
+	// Sampled recorders hold fewer samples than they counted
+	r.count += count - uint(len(samples))
+	r.mu.Unlock()
+}
+
+func (r *latencyRecorder) stats() *LatencyStats {
+	r.mu.Lock()
+	samples := append([]time.Duration(nil), r.samples...)
+	count := r.count
+	r.mu.Unlock()
+
+	stats := &LatencyStats{Count: count}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	sort.Slice(samples, func(x, y int) bool {
+		return samples[x] < samples[y]
+	})
+
+	var total time.Duration
+	for _, sample := range samples {
+		total += sample
+	}
+
+	stats.MinMs = toMs(samples[0])
+	stats.MaxMs = toMs(samples[len(samples)-1])
+	stats.MeanMs = toMs(total) / float64(len(samples))
+	stats.P50Ms = toMs(percentile(samples, 0.50))
+	stats.P90Ms = toMs(percentile(samples, 0.90))
+	stats.P99Ms = toMs(percentile(samples, 0.99))
+
+	return stats
+}
+
+// The recorded samples in milliseconds, to send them to another process
+func (r *latencyRecorder) samplesMs() []float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	samples := make([]float64, 0, len(r.samples))
+	for _, sample := range r.samples {
+		samples = append(samples, toMs(sample))
+	}
+
+	return samples
+}
+
+func newLatencyRecorderFromMs(samples []float64) *latencyRecorder {
+	r := newLatencyRecorder()
+	for _, sample := range samples {
+		r.record(time.Duration(sample * float64(time.Millisecond)))
+	}
+
+	return r
+}
+
+// Nearest-rank percentile of sorted samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Sums amounts per address from concurrent workers
+type amountCounter struct {
+	mu      sync.Mutex
+	amounts map[string]uint
+}
+
+func newAmountCounter() *amountCounter {
+	return &amountCounter{
+		amounts: make(map[string]uint),
+	}
+}
+
+func (c *amountCounter) add(address string, amount uint) {
+	c.mu.Lock()
+	c.amounts[address] += amount
+	c.mu.Unlock()
+}
+
+func (c *amountCounter) total() uint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var total uint
+	for _, amount := range c.amounts {
+		total += amount
+	}
+
+	return total
+}
+
+func (c *amountCounter) snapshot() map[string]uint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	amounts := make(map[string]uint, len(c.amounts))
+	for address, amount := range c.amounts {
+		amounts[address] = amount
+	}
+
+	return amounts
+}
@@ -0,0 +1,73 @@
+package load
+
+import (
+	"fmt"
+	"millix-performance-test/client"
+	"time"
+)
+
+const (
+	defaultRequestTimeoutSeconds = 30
+	defaultBalanceTimeoutSeconds = 10
+	defaultOutputsTimeoutSeconds = 120
+)
+
+var timeoutOperations = []string{
+	client.OperationNodeID,
+	client.OperationOutputs,
+	client.OperationPrivateKey,
+	client.OperationSign,
+	client.OperationSubmit,
+	client.OperationAddressInfo,
+	client.OperationBalance,
+	client.OperationGenerateAddress,
+}
+
+// Request timeouts of the node clients. RequestSeconds applies to every operation without an entry in
+// Operations, which is keyed by operation name.
+type TimeoutConfig struct {
+	RequestSeconds uint            `json:"request_seconds"`
+	Operations     map[string]uint `json:"operations"`
+}
+
+func (c *TimeoutConfig) validate() error {
+	if c.RequestSeconds == 0 {
+		c.RequestSeconds = defaultRequestTimeoutSeconds
+	}
+
+	if c.Operations == nil {
+		c.Operations = make(map[string]uint)
+	}
+
+	for operation := range c.Operations {
+		known := false
+		for _, timeoutOperation := range timeoutOperations {
+			known = known || operation == timeoutOperation
+		}
+		if !known {
+			return fmt.Errorf("Unknown timeout operation %q", operation)
+		}
+	}
+
+	if _, ok := c.Operations[client.OperationBalance]; !ok {
+		c.Operations[client.OperationBalance] = defaultBalanceTimeoutSeconds
+	}
+	if _, ok := c.Operations[client.OperationOutputs]; !ok {
+		c.Operations[client.OperationOutputs] = defaultOutputsTimeoutSeconds
+	}
+
+	return nil
+}
+
+func (c *TimeoutConfig) clientTimeouts() *client.Timeouts {
+	timeouts := &client.Timeouts{
+		Default:    time.Second * time.Duration(c.RequestSeconds),
+		Operations: make(map[string]time.Duration),
+	}
+
+	for operation, seconds := range c.Operations {
+		timeouts.Operations[operation] = time.Second * time.Duration(seconds)
+	}
+
+	return timeouts
+}
@@ -0,0 +1,199 @@
+package load
+
+import (
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"millix-performance-test/client"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxIdleConnections         = 100
+	defaultIdleTimeoutSeconds         = 90
+	defaultKeepAliveSeconds           = 30
+	defaultDialTimeoutSeconds         = 30
+	defaultTLSHandshakeTimeoutSeconds = 10
+)
+
+// Tuning of the HTTP transport shared by all the clients of a node. MaxIdleConnectionsPerHost defaults to
+// goroutine_count so that every worker can keep its connection open.
+type TransportConfig struct {
+	MaxIdleConnections         int  `json:"max_idle_connections"`
+	MaxIdleConnectionsPerHost  int  `json:"max_idle_connections_per_host"`
+	MaxConnectionsPerHost      int  `json:"max_connections_per_host"`
+	IdleTimeoutSeconds         uint `json:"idle_timeout_seconds"`
+	KeepAliveSeconds           uint `json:"keep_alive_seconds"`
+	DisableKeepAlives          bool `json:"disable_keep_alives"`
+	HTTP2                      bool `json:"http2"`
+	DialTimeoutSeconds         uint `json:"dial_timeout_seconds"`
+	TLSHandshakeTimeoutSeconds uint `json:"tls_handshake_timeout_seconds"`
+}
+
+func (c *TransportConfig) setDefaults(goroutineCount uint) {
+	if c.MaxIdleConnections == 0 {
+		c.MaxIdleConnections = defaultMaxIdleConnections
+	}
+	if c.MaxIdleConnectionsPerHost == 0 {
+		c.MaxIdleConnectionsPerHost = int(goroutineCount)
+	}
+	if c.IdleTimeoutSeconds == 0 {
+		c.IdleTimeoutSeconds = defaultIdleTimeoutSeconds
+	}
+	if c.KeepAliveSeconds == 0 {
+		c.KeepAliveSeconds = defaultKeepAliveSeconds
+	}
+	if c.DialTimeoutSeconds == 0 {
+		c.DialTimeoutSeconds = defaultDialTimeoutSeconds
+	}
+	if c.TLSHandshakeTimeoutSeconds == 0 {
+		c.TLSHandshakeTimeoutSeconds = defaultTLSHandshakeTimeoutSeconds
+	}
+}
+
+// How the certificate of a node is verified. Without a tls block the node must present a certificate
+// that is valid for its ip under the system roots. Insecure skips the verification and must be set
+// explicitly.
+type NodeTLSConfig struct {
+	CAFile       string   `json:"ca_file"`
+	PinnedSHA256 []string `json:"pinned_sha256"`
+	CertFile     string   `json:"cert_file"`
+	KeyFile      string   `json:"key_file"`
+	ServerName   string   `json:"server_name"`
+	Insecure     bool     `json:"insecure"`
+
+	config *tls.Config
+}
+
+// Loads the certificates so that configuration errors surface before the run
+func (c *NodeTLSConfig) load() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("cert_file and key_file must be set together")
+	}
+
+	config, err := client.NewTLSConfig(&client.TLSOptions{
+		CAFile:       c.CAFile,
+		PinnedSHA256: c.PinnedSHA256,
+		CertFile:     c.CertFile,
+		KeyFile:      c.KeyFile,
+		ServerName:   c.ServerName,
+		Insecure:     c.Insecure,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.config = config
+
+	return nil
+}
+
+func (c *TransportConfig) clientConfig(nodeConfig *NodeConfig) *client.TransportConfig {
+	var tlsConfig *tls.Config
+	if nodeConfig.TLS != nil {
+		tlsConfig = nodeConfig.TLS.config
+	}
+
+	return &client.TransportConfig{
+		TLS:                 tlsConfig,
+		MaxIdleConns:        c.MaxIdleConnections,
+		MaxIdleConnsPerHost: c.MaxIdleConnectionsPerHost,
+		MaxConnsPerHost:     c.MaxConnectionsPerHost,
+		IdleConnTimeout:     time.Second * time.Duration(c.IdleTimeoutSeconds),
+		KeepAlive:           time.Second * time.Duration(c.KeepAliveSeconds),
+		DisableKeepAlives:   c.DisableKeepAlives,
+		HTTP2:               c.HTTP2,
+		DialTimeout:         time.Second * time.Duration(c.DialTimeoutSeconds),
+		TLSHandshakeTimeout: time.Second * time.Duration(c.TLSHandshakeTimeoutSeconds),
+	}
+}
+
+// Creates the API clients of the nodes. All the clients of a node share one transport.
+type clientFactory struct {
+	config     *TransportConfig
+	timeouts   *client.Timeouts
+	limits     *rateLimiter
+	recorder   *client.Recorder
+	mu         sync.Mutex
+	transports map[string]*client.Transport
+}
+
+func newClientFactory(config *LoadConfig) *clientFactory {
+	f := &clientFactory{
+		config:     config.Transport,
+		timeouts:   config.Timeouts.clientTimeouts(),
+		limits:     newRateLimiter(config),
+		transports: make(map[string]*client.Transport),
+	}
+	if config.Recording != nil {
+		f.recorder = client.NewRecorder(config.Recording.Path)
+	}
+
+	return f
+}
+
+func (f *clientFactory) client(nodeConfig *NodeConfig) *client.Client {
+	millixClient := client.NewClientWithTransport(nodeConfig.IP, nodeConfig.Port, nodeConfig.ID, nodeConfig.Signature, nodeConfig.AddressBase, nodeConfig.KeyIdentifier, f.transport(nodeConfig))
+	millixClient.SetTimeouts(f.timeouts)
+	if limiter := f.limits.node(nodeConfig); limiter != nil {
+		millixClient.SetLimiter(limiter)
+	}
+	if f.recorder != nil {
+		millixClient.SetRecorder(f.recorder)
+	}
+
+	return millixClient
+}
+
+// A client with its own transport, no rate limiter and no recording, for requests that must
+// neither wait for the load nor show up in its statistics. Every request times out after timeout.
+func (f *clientFactory) probeClient(nodeConfig *NodeConfig, timeout time.Duration) *client.Client {
+	millixClient := client.NewClientWithTransport(nodeConfig.IP, nodeConfig.Port, nodeConfig.ID, nodeConfig.Signature, nodeConfig.AddressBase, nodeConfig.KeyIdentifier, client.NewTransport(f.config.clientConfig(nodeConfig)))
+	millixClient.SetTimeouts(&client.Timeouts{Default: timeout})
+
+	return millixClient
+}
+
+func (f *clientFactory) transport(nodeConfig *NodeConfig) *client.Transport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	transport, ok := f.transports[nodeConfig.ID]
+	if !ok {
+		transport = client.NewTransport(f.config.clientConfig(nodeConfig))
+		f.transports[nodeConfig.ID] = transport
+	}
+
+	return transport
+}
+
+// Connection reuse statistics per node id
+func (f *clientFactory) stats() map[string]*client.ConnectionStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make(map[string]*client.ConnectionStats)
+	for id, transport := range f.transports {
+		stats[id] = transport.Stats()
+	}
+
+	return stats
+}
+
+func (f *clientFactory) resetStats() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, transport := range f.transports {
+		transport.ResetStats()
+	}
+}
+
+// Closes the traffic recording, if any
+func (f *clientFactory) close() error {
+	if f.recorder == nil {
+		return nil
+	}
+
+	return f.recorder.Close()
+}
@@ -0,0 +1,166 @@
+package load
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"time"
+)
+
+const (
+	defaultStableTimeout      = 300
+	defaultStablePollInterval = 5
+
+	RoleFunder   = "funder"
+	RoleNode     = "node"
+	RoleReceiver = "receiver"
+)
+
+type VerificationResult struct {
+	Stable              bool            `json:"stable"`
+	TimeToStableSeconds float64         `json:"time_to_stable_seconds"`
+	TotalBefore         uint            `json:"total_before"`
+	TotalAfter          uint            `json:"total_after"`
+	Conserved           bool            `json:"conserved"`
+	Balances            []*BalanceCheck `json:"balances"`
+	Discrepancies       []string        `json:"discrepancies"`
+}
+
+type BalanceCheck struct {
+	Address        string `json:"address"`
+	Role           string `json:"role"`
+	Before         uint   `json:"before"`
+	After          uint   `json:"after"`
+	ExpectedChange int64  `json:"expected_change"`
+	ActualChange   int64  `json:"actual_change"`
+}
+
+// The stable balances of the tracked addresses at one point of the run
+type balanceSnapshot struct {
+	balances     map[string]uint
+	stable       bool
+	timeToStable time.Duration
+}
+
+// Lists the addresses whose balances are verified, with their role
+func (o *Orchestrator) trackedAddresses() map[string]string {
+	addresses := make(map[string]string)
+
+	for _, receiver := range o.receivers {
+		addresses[receiver.address()] = RoleReceiver
+	}
+
+	for i, nodeConfig := range o.nodeConfigs {
+		if i == 0 {
+			addresses[nodeAddress(nodeConfig)] = RoleFunder
+		} else {
+			addresses[nodeAddress(nodeConfig)] = RoleNode
+		}
+	}
+
+	return addresses
+}
+
+// Waits until no tracked address has unstable funds and returns the stable balances.
+// When the timeout expires the last balances are returned and the snapshot is marked unstable.
+func (o *Orchestrator) snapshotBalances(config *VerificationConfig) (*balanceSnapshot, error) {
+	o.setPhase(phaseVerification)
+
+	startTime := time.Now()
+	deadline := startTime.Add(time.Second * time.Duration(config.StableTimeoutSeconds))
+	addresses := o.trackedAddresses()
+
+	for {
+		balances := make(map[string]uint, len(addresses))
+		stable := true
+
+		for address := range addresses {
+			stableBalance, unstableBalance, err := o.funderClient.GetBalance(address)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("Failed to get balance of %s", address))
+			}
+
+			if unstableBalance > 0 {
+				stable = false
+			}
+			balances[address] = stableBalance
+		}
+
+		if stable || time.Now().After(deadline) {
+			return &balanceSnapshot{
+				balances:     balances,
+				stable:       stable,
+				timeToStable: time.Since(startTime),
+			}, nil
+		}
+
+		fmt.Printf("[Orchestrator][Verification] Waiting for balances to stabilise.\n")
+		time.Sleep(time.Second * time.Duration(config.PollIntervalSeconds))
+	}
+}
+
+// Compares the balance changes between the snapshots with the amounts that the load clients
+// successfully transferred, and checks that the total funds are conserved.
+func (o *Orchestrator) verifyLedger(before, after *balanceSnapshot, nodeResults []*NodeResult, doubleSpendResults []*DoubleSpendResult) *VerificationResult {
+	expectedChanges := make(map[string]int64)
+
+	for _, nodeResult := range nodeResults {
+		for address, amount := range nodeResult.OutboundByAddress {
+			expectedChanges[address] += int64(amount)
+			expectedChanges[nodeResult.Address] -= int64(amount)
+		}
+	}
+
+	for _, doubleSpendRes := range doubleSpendResults {
+		for address, amount := range doubleSpendRes.transfers.snapshot() {
+			expectedChanges[address] += int64(amount)
+			expectedChanges[doubleSpendRes.node] -= int64(amount)
+		}
+	}
+
+	res := &VerificationResult{
+		Stable:              after.stable,
+		TimeToStableSeconds: after.timeToStable.Seconds(),
+		Balances:            make([]*BalanceCheck, 0, len(after.balances)),
+		Discrepancies:       make([]string, 0),
+	}
+
+	if !before.stable {
+		res.Discrepancies = append(res.Discrepancies, "Balances were not stable before the run")
+	}
+
+	if !after.stable {
+		res.Discrepancies = append(res.Discrepancies, "Balances did not stabilise after the run")
+	}
+
+	for address, role := range o.trackedAddresses() {
+		check := &BalanceCheck{
+			Address:        address,
+			Role:           role,
+			Before:         before.balances[address],
+			After:          after.balances[address],
+			ExpectedChange: expectedChanges[address],
+			ActualChange:   int64(after.balances[address]) - int64(before.balances[address]),
+		}
+
+		res.TotalBefore += check.Before
+		res.TotalAfter += check.After
+		res.Balances = append(res.Balances, check)
+
+		if check.ExpectedChange != check.ActualChange {
+			res.Discrepancies = append(res.Discrepancies, fmt.Sprintf("%s %s changed by %d, expected %d", role, address, check.ActualChange, check.ExpectedChange))
+		}
+	}
+
+	sort.Slice(res.Balances, func(x, y int) bool {
+		return res.Balances[x].Address < res.Balances[y].Address
+	})
+	sort.Strings(res.Discrepancies)
+
+	res.Conserved = res.TotalBefore == res.TotalAfter
+	if !res.Conserved {
+		res.Discrepancies = append(res.Discrepancies, fmt.Sprintf("Total funds changed from %d to %d", res.TotalBefore, res.TotalAfter))
+	}
+
+	return res
+}
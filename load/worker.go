@@ -0,0 +1,274 @@
+package load
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"millix-performance-test/client"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	workerPollInterval      = time.Second
+	workerStartPollInterval = 100 * time.Millisecond
+	// A worker gives up when the coordinator is unreachable for this long
+	workerUnreachableTimeout = 2 * time.Minute
+	// Carries the shared token of the coordinator and its workers
+	workerTokenHeader = "X-Loader-Token"
+)
+
+// What a worker sends back to the coordinator after its timed window. The window is measured from the
+// start signal of the coordinator, so the coordinator can place it without comparing machine clocks.
+type WorkerReport struct {
+	WorkerID           string                             `json:"worker_id"`
+	StartOffsetSeconds float64                            `json:"start_offset_seconds"`
+	DurationSeconds    float64                            `json:"duration_seconds"`
+	Nodes              []*NodeResult                      `json:"nodes"`
+	Samples            []*NodeSamples                     `json:"samples"`
+	Connections        map[string]*client.ConnectionStats `json:"connections"`
+	NodeChecks         []*NodeMetadata                    `json:"node_checks"`
+}
+
+// Latency and rate limit wait samples of a node in milliseconds, so the coordinator can compute the
+// percentiles over all workers
+type NodeSamples struct {
+	Address   string               `json:"address"`
+	Latencies map[string][]float64 `json:"latencies_ms"`
+	Waits     map[string][]float64 `json:"waits_ms"`
+}
+
+// Prepares the assigned nodes, which the coordinator already funded, waits for the start time
+// returned by start and sends the transactions
+func (o *Orchestrator) Work(start func() (time.Time, error)) (*WorkerReport, error) {
+	metadata, err := o.collectMetadata()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to collect run metadata")
+	}
+
+	if err := o.prepareReceivers(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare receivers")
+	}
+
+	if err := o.prepareOutputs(); err != nil {
+		return nil, errors.Wrap(err, "Failed to prepare transaction outputs")
+	}
+
+	if err := o.prepareWorkers(); err != nil {
+		return nil, err
+	}
+
+	if o.config.Presign {
+		if err := o.presignTransactions(); err != nil {
+			return nil, errors.Wrap(err, "Failed to pre-sign transactions")
+		}
+	}
+
+	startAt, err := start()
+	if err != nil {
+		return nil, err
+	}
+	time.Sleep(time.Until(startAt))
+
+	startTime := time.Now()
+
+	nodeResults, err := o.sendTransactions()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to perform load test")
+	}
+
+	duration := time.Since(startTime)
+	o.confirmMutations(nodeResults)
+
+	report := &WorkerReport{
+		StartOffsetSeconds: startTime.Sub(startAt).Seconds(),
+		DurationSeconds:    duration.Seconds(),
+		Nodes:              nodeResults,
+		Samples:            make([]*NodeSamples, 0, len(nodeResults)),
+		Connections:        o.clients.stats(),
+		NodeChecks:         metadata.Nodes,
+	}
+
+	for _, nodeResult := range nodeResults {
+		samples := &NodeSamples{
+			Address:   nodeResult.Address,
+			Latencies: make(map[string][]float64),
+			Waits:     make(map[string][]float64),
+		}
+		for scenario, recorder := range nodeResult.latencies {
+			samples.Latencies[scenario] = recorder.samplesMs()
+		}
+		for class, recorder := range nodeResult.waits {
+			samples.Waits[class] = recorder.samplesMs()
+		}
+		report.Samples = append(report.Samples, samples)
+	}
+
+	return report, nil
+}
+
+type workerRegistration struct {
+	WorkerID string `json:"worker_id"`
+}
+
+type workerAssignment struct {
+	Config *LoadConfig `json:"config"`
+}
+
+type workerStart struct {
+	StartInMs int64 `json:"start_in_ms"`
+}
+
+type workerFailure struct {
+	WorkerID string `json:"worker_id"`
+	Error    string `json:"error"`
+}
+
+type worker struct {
+	coordinator string
+	id          string
+	token       string
+	httpClient  *http.Client
+}
+
+// Registers with the coordinator, runs the assigned share of the load test and reports back
+func RunWorker(coordinatorURL, workerID, token string) error {
+	if token == "" {
+		return errors.New("A shared token is needed")
+	}
+
+	w := &worker{
+		coordinator: coordinatorURL,
+		id:          workerID,
+		token:       token,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	fmt.Printf("[Worker] Registering as %s with %s.\n", w.id, w.coordinator)
+	if err := w.post("/register", &workerRegistration{WorkerID: w.id}); err != nil {
+		return errors.Wrap(err, "Failed to register")
+	}
+
+	fmt.Printf("[Worker] Waiting for the assignment.\n")
+	var assignment *workerAssignment
+	if err := w.poll("/assignment", workerPollInterval, &assignment); err != nil {
+		return errors.Wrap(err, "Failed to get the assignment")
+	}
+
+	config := assignment.Config
+	if err := config.Validate(); err != nil {
+		w.fail(err)
+		return errors.Wrap(err, "Invalid assignment")
+	}
+	fmt.Printf("[Worker] Assigned %d nodes.\n", len(config.NodeConfigs))
+
+	orchestrator := NewOrchestrator(config)
+	defer orchestrator.Close()
+
+	report, err := orchestrator.Work(func() (time.Time, error) {
+		fmt.Printf("[Worker] Ready. Waiting for the start.\n")
+		if err := w.post("/ready", &workerRegistration{WorkerID: w.id}); err != nil {
+			return time.Time{}, errors.Wrap(err, "Failed to report ready")
+		}
+
+		var start *workerStart
+		if err := w.poll("/start", workerStartPollInterval, &start); err != nil {
+			return time.Time{}, errors.Wrap(err, "Failed to get the start time")
+		}
+
+		return time.Now().Add(time.Duration(start.StartInMs) * time.Millisecond), nil
+	})
+	if err != nil {
+		w.fail(err)
+		return err
+	}
+
+	report.WorkerID = w.id
+	fmt.Printf("[Worker] Sending the report.\n")
+	if err := w.post("/report", report); err != nil {
+		return errors.Wrap(err, "Failed to send the report")
+	}
+
+	return nil
+}
+
+// Tells the coordinator to abort the run
+func (w *worker) fail(err error) {
+	if postErr := w.post("/fail", &workerFailure{WorkerID: w.id, Error: err.Error()}); postErr != nil {
+		fmt.Printf("[Worker] ERROR. Failed to report the failure: %s.\n", postErr)
+	}
+}
+
+// Retries while the coordinator is unreachable, so workers can be started before the coordinator
+func (w *worker) post(path string, body interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(workerUnreachableTimeout)
+	for {
+		req, err := http.NewRequest(http.MethodPost, w.coordinator+path, bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := w.do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("Coordinator answered %s", resp.Status)
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(workerPollInterval)
+	}
+}
+
+// Polls until the coordinator answers with content, which is decoded into res
+func (w *worker) poll(path string, interval time.Duration, res interface{}) error {
+	query := url.Values{"worker_id": {w.id}}
+	deadline := time.Now().Add(workerUnreachableTimeout)
+	for {
+		req, err := http.NewRequest(http.MethodGet, w.coordinator+path+"?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := w.do(req)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return err
+			}
+			time.Sleep(interval)
+			continue
+		}
+		deadline = time.Now().Add(workerUnreachableTimeout)
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			err := json.NewDecoder(resp.Body).Decode(res)
+			resp.Body.Close()
+			return err
+		case http.StatusNoContent:
+			resp.Body.Close()
+			time.Sleep(interval)
+		default:
+			resp.Body.Close()
+			return fmt.Errorf("Coordinator answered %s", resp.Status)
+		}
+	}
+}
+
+func (w *worker) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set(workerTokenHeader, w.token)
+
+	return w.httpClient.Do(req)
+}
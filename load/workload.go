@@ -0,0 +1,122 @@
+package load
+
+import (
+	"math/rand"
+	"millix-performance-test/client"
+)
+
+const (
+	// One input paid in full to a single receiver
+	ScenarioTransfer = "transfer"
+	// One input split over several receiver outputs
+	ScenarioFanOut = "fan_out"
+	// Several inputs merged into a single receiver output
+	ScenarioConsolidation = "consolidation"
+
+	defaultScenarioWidth = 4
+)
+
+// An output prepared by the load client that can be spent by the load test
+type preparedInput struct {
+	input  *client.TransactionInput
+	amount uint
+}
+
+// An unsigned transaction together with the scenario that produced it.
+// Signed is set when the transaction is signed ahead of the timed window.
+type pendingTransaction struct {
+	scenario string
+	unsigned *client.UnsignedTransaction
+	signed   *client.Transaction
+}
+
+// Draws scenarios from the weighted workload mix
+type workload struct {
+	scenarios   []*ScenarioWeight
+	totalWeight uint
+	rng         *rand.Rand
+}
+
+func newWorkload(mix []*ScenarioWeight, rng *rand.Rand) *workload {
+	var totalWeight uint
+	for _, scenario := range mix {
+		totalWeight += scenario.Weight
+	}
+
+	return &workload{
+		scenarios:   mix,
+		totalWeight: totalWeight,
+		rng:         rng,
+	}
+}
+
+func (w *workload) next() *ScenarioWeight {
+	if w.totalWeight == 0 {
+		return w.scenarios[0]
+	}
+
+	pick := uint(w.rng.Int63n(int64(w.totalWeight)))
+	for _, scenario := range w.scenarios {
+		if pick < scenario.Weight {
+			return scenario
+		}
+		pick -= scenario.Weight
+	}
+
+	return w.scenarios[len(w.scenarios)-1]
+}
+
+// Builds the unsigned transaction for the scenario, consuming inputs from the front of the slice.
+// Returns the transaction and the remaining inputs.
+func buildTransaction(scenario *ScenarioWeight, inputs []*preparedInput, receivers receiverSelector) (*client.UnsignedTransaction, []*preparedInput) {
+	inputCount := 1
+	if scenario.Scenario == ScenarioConsolidation {
+		inputCount = int(scenario.Width)
+		if inputCount > len(inputs) {
+			inputCount = len(inputs)
+		}
+	}
+
+	consumed := inputs[:inputCount]
+
+	var total uint
+	inputList := make([]*client.TransactionInput, 0, len(consumed))
+	for _, prepared := range consumed {
+		total += prepared.amount
+		inputList = append(inputList, prepared.input)
+	}
+
+	outputCount := uint(1)
+	if scenario.Scenario == ScenarioFanOut {
+		outputCount = scenario.Width
+		// Only outputs recycled during a soak can be smaller than the width
+		if outputCount > total {
+			outputCount = total
+		}
+	}
+
+	outputList := make([]*client.TransactionOutput, 0, outputCount)
+	for i := uint(0); i < outputCount; i++ {
+		amount := total / outputCount
+		// The remainder of the split goes to the first output
+		if i == 0 {
+			amount += total % outputCount
+		}
+
+		receiver := receivers.next()
+		outputList = append(outputList, &client.TransactionOutput{
+			AddressBase:          receiver.addressBase,
+			AddressVersion:       "lal",
+			AddressKeyIdentifier: receiver.keyIdentifier,
+			Amount:               amount,
+		})
+	}
+
+	unsignedTx := &client.UnsignedTransaction{
+		TransactionVersion: "la0l",
+		InputList:          inputList,
+		OutputList:         outputList,
+	}
+
+	return unsignedTx, inputs[inputCount:]
+}
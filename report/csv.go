@@ -0,0 +1,146 @@
+package report
+
+import (
+	"encoding/csv"
+	"io"
+	"millix-performance-test/load"
+	"strconv"
+	"time"
+)
+
+// A single row with the totals of the run and the latency percentiles of every scenario
+type summaryCSVWriter struct{}
+
+// One row per node
+type nodesCSVWriter struct{}
+
+func (*summaryCSVWriter) Write(w io.Writer, res *load.Result) error {
+	scenarios := scenarioNames(res.Scenarios)
+	header := []string{
+		"start_time", "end_time", "node_count", "total_transaction_count", "failed_transaction_count",
+		"error_rate", "timeout_count", "achieved_tps", "assertions_passed",
+	}
+	header = append(header, latencyHeader(scenarios)...)
+
+	row := []string{
+		formatTime(res.StartTime),
+		formatTime(res.EndTime),
+		formatUint(res.NodeCount),
+		formatUint(res.TotalTransactions),
+		formatUint(res.Failed),
+		formatFloat(res.ErrorRate()),
+		formatUint(timeoutCount(res.Timeouts)),
+		formatFloat(res.AchievedTps),
+		"",
+	}
+	if res.Assertions != nil {
+		row[len(row)-1] = strconv.FormatBool(res.Assertions.Passed)
+	}
+	row = append(row, latencyColumns(scenarios, res.Scenarios)...)
+
+	return writeCSV(w, header, [][]string{row})
+}
+
+func (*summaryCSVWriter) Extension() string {
+	return "csv"
+}
+
+func (*nodesCSVWriter) Write(w io.Writer, res *load.Result) error {
+	scenarioSet := make(map[string]*load.ScenarioResult)
+	for _, node := range res.Nodes {
+		for scenario, scenarioRes := range node.Scenarios {
+			scenarioSet[scenario] = scenarioRes
+		}
+	}
+	scenarios := scenarioNames(scenarioSet)
+
+	header := []string{
+		"address", "total_transaction_count", "failed_transaction_count", "error_rate", "timeout_count",
+		"achieved_tps", "inbound_amount", "outbound_amount",
+	}
+	header = append(header, latencyHeader(scenarios)...)
+
+	rows := make([][]string, 0, len(res.Nodes))
+	for _, node := range res.Nodes {
+		row := []string{
+			node.Address,
+			formatUint(node.TotalTransactions),
+			formatUint(node.Failed),
+			formatFloat(node.ErrorRate()),
+			formatUint(timeoutCount(node.Timeouts)),
+			formatFloat(node.AchievedTps),
+			formatUint(node.Inbound),
+			formatUint(node.Outbound),
+		}
+		rows = append(rows, append(row, latencyColumns(scenarios, node.Scenarios)...))
+	}
+
+	return writeCSV(w, header, rows)
+}
+
+func (*nodesCSVWriter) Extension() string {
+	return "nodes.csv"
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+func latencyHeader(scenarios []string) []string {
+	header := make([]string, 0, 3*len(scenarios))
+	for _, scenario := range scenarios {
+		header = append(header, scenario+"_p50_ms", scenario+"_p90_ms", scenario+"_p99_ms")
+	}
+
+	return header
+}
+
+// Scenarios without latencies leave their columns empty
+func latencyColumns(scenarios []string, results map[string]*load.ScenarioResult) []string {
+	columns := make([]string, 0, 3*len(scenarios))
+	for _, scenario := range scenarios {
+		scenarioRes, ok := results[scenario]
+		if !ok || scenarioRes.Latency == nil {
+			columns = append(columns, "", "", "")
+			continue
+		}
+
+		latency := scenarioRes.Latency
+		columns = append(columns, formatFloat(latency.P50Ms), formatFloat(latency.P90Ms), formatFloat(latency.P99Ms))
+	}
+
+	return columns
+}
+
+func timeoutCount(timeouts map[string]uint) uint {
+	var count uint
+	for _, operationCount := range timeouts {
+		count += operationCount
+	}
+
+	return count
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func formatUint(value uint) string {
+	return strconv.FormatUint(uint64(value), 10)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,136 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"millix-performance-test/load"
+	"testing"
+)
+
+// Parses the written CSV into one map per row, keyed by the header
+func readCSV(t *testing.T, content []byte) []map[string]string {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		if len(record) != len(records[0]) {
+			t.Fatalf("Row has %d columns, the header %d", len(record), len(records[0]))
+		}
+
+		row := make(map[string]string)
+		for i, column := range records[0] {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func TestNodesCSVAlignsScenarios(t *testing.T) {
+	res := &load.Result{
+		Nodes: []*load.NodeResult{
+			{
+				Address:           "a",
+				TotalTransactions: 10,
+				AchievedTps:       5,
+				Scenarios: map[string]*load.ScenarioResult{
+					load.ScenarioTransfer: {Count: 10, Latency: &load.LatencyStats{P50Ms: 1, P90Ms: 2, P99Ms: 3}},
+				},
+			},
+			{
+				Address:           "b",
+				TotalTransactions: 4,
+				Failed:            1,
+				Scenarios: map[string]*load.ScenarioResult{
+					load.ScenarioFanOut:        {Count: 2, Latency: &load.LatencyStats{P50Ms: 4, P90Ms: 5, P99Ms: 6}},
+					load.ScenarioConsolidation: {Count: 2},
+				},
+			},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := (&nodesCSVWriter{}).Write(&buffer, res); err != nil {
+		t.Fatal(err)
+	}
+	rows := readCSV(t, buffer.Bytes())
+	if len(rows) != 2 {
+		t.Fatalf("%d rows, want 2", len(rows))
+	}
+
+	tests := []struct {
+		row    int
+		column string
+		value  string
+	}{
+		{0, "address", "a"},
+		{0, "transfer_p50_ms", "1"},
+		{0, "transfer_p99_ms", "3"},
+		{0, "fan_out_p99_ms", ""},
+		{0, "consolidation_p99_ms", ""},
+		{1, "address", "b"},
+		{1, "error_rate", "0.2"},
+		{1, "transfer_p50_ms", ""},
+		{1, "fan_out_p50_ms", "4"},
+		{1, "fan_out_p90_ms", "5"},
+		{1, "fan_out_p99_ms", "6"},
+		// A scenario without latencies leaves its columns empty
+		{1, "consolidation_p50_ms", ""},
+	}
+
+	for _, test := range tests {
+		value, ok := rows[test.row][test.column]
+		if !ok {
+			t.Errorf("No column %s", test.column)
+			continue
+		}
+		if value != test.value {
+			t.Errorf("Row %d %s = %q, want %q", test.row, test.column, value, test.value)
+		}
+	}
+}
+
+func TestSummaryCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		assertions *load.AssertionResult
+		passed     string
+	}{
+		{"without assertions", nil, ""},
+		{"passed", &load.AssertionResult{Passed: true}, "true"},
+		{"failed", &load.AssertionResult{Passed: false}, "false"},
+	}
+
+	for _, test := range tests {
+		res := &load.Result{
+			TotalTransactions: 8,
+			Failed:            2,
+			Timeouts:          map[string]uint{"sign": 1, "submit": 2},
+			Assertions:        test.assertions,
+			Scenarios: map[string]*load.ScenarioResult{
+				load.ScenarioTransfer: {Count: 8, Latency: &load.LatencyStats{P50Ms: 1.5, P90Ms: 2, P99Ms: 3}},
+			},
+		}
+
+		var buffer bytes.Buffer
+		if err := (&summaryCSVWriter{}).Write(&buffer, res); err != nil {
+			t.Fatal(err)
+		}
+		rows := readCSV(t, buffer.Bytes())
+		if len(rows) != 1 {
+			t.Fatalf("%s: %d rows, want 1", test.name, len(rows))
+		}
+
+		row := rows[0]
+		if row["assertions_passed"] != test.passed {
+			t.Errorf("%s: assertions_passed = %q, want %q", test.name, row["assertions_passed"], test.passed)
+		}
+		if row["error_rate"] != "0.2" || row["timeout_count"] != "3" || row["transfer_p50_ms"] != "1.5" {
+			t.Errorf("%s: unexpected row %v", test.name, row)
+		}
+	}
+}
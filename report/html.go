@@ -0,0 +1,238 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"html/template"
+	"io"
+	"millix-performance-test/load"
+	"sort"
+)
+
+type section struct {
+	Title string
+	Note  string
+	Chart template.HTML
+}
+
+type nodeRow struct {
+	Address     string
+	Sent        uint
+	Failed      uint
+	AchievedTps float64
+	P99Ms       float64
+}
+
+type page struct {
+	Result   *load.Result
+	Sections []*section
+	Nodes    []*nodeRow
+	Config   string
+}
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Millix load test report</title>
+<style>
+body { font-family: sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; }
+h2 { font-size: 17px; margin-top: 32px; }
+table { border-collapse: collapse; font-size: 13px; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+td:first-child, th:first-child { text-align: left; }
+pre { background: #f4f4f4; padding: 12px; font-size: 12px; overflow-x: auto; }
+.note { color: #666; font-size: 13px; }
+</style>
+</head>
+<body>
+<h1>Millix load test report</h1>
+<table>
+{{with .Result.Metadata}}<tr><th>Run</th><td>{{.RunID}}</td></tr>
+<tr><th>Loader</th><td>{{.LoaderVersion}} {{.VCSRevision}} ({{.GoVersion}})</td></tr>
+<tr><th>Host</th><td>{{.Hostname}}, {{.CPUCount}} CPUs</td></tr>
+{{range $label, $value := .Labels}}<tr><th>{{$label}}</th><td>{{$value}}</td></tr>
+{{end}}{{end}}<tr><th>Start</th><td>{{if .Result.StartTime}}{{.Result.StartTime.Format "2006-01-02 15:04:05 MST"}}{{end}}</td></tr>
+<tr><th>End</th><td>{{if .Result.EndTime}}{{.Result.EndTime.Format "2006-01-02 15:04:05 MST"}}{{end}}</td></tr>
+<tr><th>Nodes</th><td>{{.Result.NodeCount}}</td></tr>
+<tr><th>Transactions</th><td>{{.Result.TotalTransactions}}</td></tr>
+<tr><th>Failed</th><td>{{.Result.Failed}}</td></tr>
+<tr><th>Achieved TPS</th><td>{{printf "%.2f" .Result.AchievedTps}}</td></tr>
+{{if .Result.Assertions}}<tr><th>Assertions</th><td>{{if .Result.Assertions.Passed}}passed{{else}}FAILED{{end}}</td></tr>{{end}}
+</table>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+{{if .Note}}<p class="note">{{.Note}}</p>{{end}}
+{{.Chart}}
+{{end}}
+<h2>Nodes</h2>
+<table>
+<tr><th>Address</th><th>Transactions</th><th>Failed</th><th>Achieved TPS</th><th>Highest p99 ms</th></tr>
+{{range .Nodes}}<tr><td>{{.Address}}</td><td>{{.Sent}}</td><td>{{.Failed}}</td><td>{{printf "%.2f" .AchievedTps}}</td><td>{{printf "%.1f" .P99Ms}}</td></tr>
+{{end}}</table>
+<h2>Effective config</h2>
+{{if .Config}}<pre>{{.Config}}</pre>{{else}}<p class="note">The config of the run is not available.</p>{{end}}
+</body>
+</html>
+`))
+
+// Writes a self-contained HTML report of the result. The config is optional and must be
+// redacted by the caller.
+func WriteHTML(w io.Writer, res *load.Result, config *load.LoadConfig) error {
+	p := &page{
+		Result:   res,
+		Sections: make([]*section, 0),
+		Nodes:    nodeRows(res),
+	}
+
+	p.Sections = append(p.Sections, throughputSection(res), latencySection(res))
+	p.Sections = append(p.Sections, nodeSections(p.Nodes)...)
+	p.Sections = append(p.Sections, errorSection(res))
+	if len(res.Liveness) > 0 {
+		p.Sections = append(p.Sections, livenessSection(res.Liveness))
+	}
+
+	if config != nil {
+		content, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "Failed to marshal config")
+		}
+		p.Config = string(content)
+	}
+
+	if err := pageTemplate.Execute(w, p); err != nil {
+		return errors.Wrap(err, "Failed to render report")
+	}
+
+	return nil
+}
+
+func throughputSection(res *load.Result) *section {
+	s := &section{Title: "Throughput over time"}
+	if len(res.Intervals) == 0 {
+		s.Note = "Throughput over time is only recorded by soak runs."
+		return s
+	}
+
+	tps := &series{name: "achieved TPS"}
+	p99 := &series{name: "p99 latency ms"}
+	for _, interval := range res.Intervals {
+		tps.points = append(tps.points, point{x: interval.StartSeconds, y: interval.AchievedTps})
+		if interval.Latency != nil {
+			p99.points = append(p99.points, point{x: interval.StartSeconds, y: interval.Latency.P99Ms})
+		}
+	}
+
+	s.Chart = lineChart("seconds since start", "TPS", []*series{tps}) + lineChart("seconds since start", "ms", []*series{p99})
+	return s
+}
+
+func latencySection(res *load.Result) *section {
+	s := &section{Title: "Latency distribution", Note: "Minimum, mean and percentiles of the transaction latency per scenario."}
+	for _, scenario := range scenarioNames(res.Scenarios) {
+		latency := res.Scenarios[scenario].Latency
+		if latency == nil {
+			continue
+		}
+
+		s.Chart += barChart("ms", []*bar{
+			{label: scenario + " min", value: latency.MinMs},
+			{label: scenario + " mean", value: latency.MeanMs},
+			{label: scenario + " p50", value: latency.P50Ms},
+			{label: scenario + " p90", value: latency.P90Ms},
+			{label: scenario + " p99", value: latency.P99Ms},
+			{label: scenario + " max", value: latency.MaxMs},
+		})
+	}
+
+	return s
+}
+
+func nodeSections(nodes []*nodeRow) []*section {
+	tps := make([]*bar, 0, len(nodes))
+	p99 := make([]*bar, 0, len(nodes))
+	for _, node := range nodes {
+		tps = append(tps, &bar{label: node.Address, value: node.AchievedTps})
+		p99 = append(p99, &bar{label: node.Address, value: node.P99Ms})
+	}
+
+	return []*section{
+		{Title: "Achieved TPS per node", Chart: barChart("tps", tps)},
+		{Title: "Highest p99 latency per node", Note: "The highest p99 latency of the node's scenarios.", Chart: barChart("ms", p99)},
+	}
+}
+
+func errorSection(res *load.Result) *section {
+	bars := []*bar{{label: "failed transactions", value: float64(res.Failed)}}
+
+	operations := make([]string, 0, len(res.Timeouts))
+	for operation := range res.Timeouts {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	for _, operation := range operations {
+		bars = append(bars, &bar{label: fmt.Sprintf("%s timeouts", operation), value: float64(res.Timeouts[operation])})
+	}
+
+	if res.Mutations != nil {
+		bars = append(bars, &bar{label: "accepted invalid transactions", value: float64(len(res.Mutations.Accepted))})
+	}
+	if res.DoubleSpend != nil {
+		bars = append(bars, &bar{label: "double spends both accepted", value: float64(res.DoubleSpend.BothAccepted)})
+		bars = append(bars, &bar{label: "unresolved double spends", value: float64(res.DoubleSpend.Unresolved)})
+	}
+	if res.Verification != nil {
+		bars = append(bars, &bar{label: "ledger discrepancies", value: float64(len(res.Verification.Discrepancies))})
+	}
+
+	return &section{Title: "Errors", Chart: barChart("", bars)}
+}
+
+func livenessSection(nodes []*load.NodeLiveness) *section {
+	availability := make([]*bar, 0, len(nodes))
+	transitions := 0
+	for _, node := range nodes {
+		availability = append(availability, &bar{label: node.NodeID, value: 100 * node.Availability})
+		if len(node.Timeline) > 1 {
+			transitions += len(node.Timeline) - 1
+		}
+	}
+
+	return &section{
+		Title: "Node availability",
+		Note:  fmt.Sprintf("Share of successful liveness probes per node. %d up or down transitions.", transitions),
+		Chart: barChart("%", availability),
+	}
+}
+
+func nodeRows(res *load.Result) []*nodeRow {
+	rows := make([]*nodeRow, 0, len(res.Nodes))
+	for _, node := range res.Nodes {
+		row := &nodeRow{
+			Address:     node.Address,
+			Sent:        node.TotalTransactions,
+			Failed:      node.Failed,
+			AchievedTps: node.AchievedTps,
+		}
+		for _, scenario := range node.Scenarios {
+			if scenario.Latency != nil && scenario.Latency.P99Ms > row.P99Ms {
+				row.P99Ms = scenario.Latency.P99Ms
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func scenarioNames(scenarios map[string]*load.ScenarioResult) []string {
+	names := make([]string, 0, len(scenarios))
+	for scenario := range scenarios {
+		names = append(names, scenario)
+	}
+
+	sort.Strings(names)
+	return names
+}
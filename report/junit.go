@@ -0,0 +1,95 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"millix-performance-test/load"
+)
+
+// Every assertion check and every node is a test case. A node fails when any of its
+// transactions failed.
+type junitWriter struct{}
+
+type junitSuites struct {
+	XMLName xml.Name      `xml:"testsuites"`
+	Suites  []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+func (*junitWriter) Write(w io.Writer, res *load.Result) error {
+	var duration float64
+	if res.StartTime != nil && res.EndTime != nil {
+		duration = res.EndTime.Sub(*res.StartTime).Seconds()
+	}
+
+	suites := &junitSuites{}
+
+	if res.Assertions != nil {
+		suite := &junitSuite{Name: "assertions", Time: duration}
+		for _, check := range res.Assertions.Checks {
+			testCase := &junitCase{
+				Name:      check.Name,
+				ClassName: "millix.load.assertions",
+				SystemOut: fmt.Sprintf("limit %g, actual %g", check.Limit, check.Actual),
+			}
+			if !check.Passed {
+				testCase.Failure = &junitFailure{Message: fmt.Sprintf("%s: actual %g, limit %g", check.Name, check.Actual, check.Limit)}
+			}
+			suite.add(testCase)
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	suite := &junitSuite{Name: "nodes", Time: duration}
+	for _, node := range res.Nodes {
+		testCase := &junitCase{
+			Name:      node.Address,
+			ClassName: "millix.load.nodes",
+			SystemOut: fmt.Sprintf("%d transactions, %d failed, %.2f tps", node.TotalTransactions, node.Failed, node.AchievedTps),
+		}
+		if node.Failed > 0 {
+			testCase.Failure = &junitFailure{Message: fmt.Sprintf("%d of %d transactions failed", node.Failed, node.TotalTransactions+node.Failed)}
+		}
+		suite.add(testCase)
+	}
+	suites.Suites = append(suites.Suites, suite)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(suites)
+}
+
+func (*junitWriter) Extension() string {
+	return "junit.xml"
+}
+
+func (s *junitSuite) add(testCase *junitCase) {
+	s.Cases = append(s.Cases, testCase)
+	s.Tests++
+	if testCase.Failure != nil {
+		s.Failures++
+	}
+}
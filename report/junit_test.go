@@ -0,0 +1,79 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"millix-performance-test/load"
+	"testing"
+)
+
+func TestJUnitFailureCounts(t *testing.T) {
+	nodes := []*load.NodeResult{
+		{Address: "a", TotalTransactions: 10},
+		{Address: "b", TotalTransactions: 8, Failed: 2},
+	}
+
+	tests := []struct {
+		name       string
+		assertions *load.AssertionResult
+		// Tests and failures per suite
+		suites map[string][2]int
+	}{
+		{
+			name:   "without assertions",
+			suites: map[string][2]int{"nodes": {2, 1}},
+		},
+		{
+			name: "passed assertions",
+			assertions: &load.AssertionResult{Passed: true, Checks: []*load.AssertionCheck{
+				{Name: "min_tps", Limit: 1, Actual: 2, Passed: true},
+			}},
+			suites: map[string][2]int{"assertions": {1, 0}, "nodes": {2, 1}},
+		},
+		{
+			name: "failed assertions",
+			assertions: &load.AssertionResult{Passed: false, Checks: []*load.AssertionCheck{
+				{Name: "min_tps", Limit: 3, Actual: 2, Passed: false},
+				{Name: "max_error_rate", Limit: 0, Actual: 0.1, Passed: false},
+				{Name: "max_p99_submit_ms a", Limit: 100, Actual: 50, Passed: true},
+			}},
+			suites: map[string][2]int{"assertions": {3, 2}, "nodes": {2, 1}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			res := &load.Result{Nodes: nodes, Assertions: test.assertions}
+			if err := (&junitWriter{}).Write(&buffer, res); err != nil {
+				t.Fatal(err)
+			}
+
+			var suites junitSuites
+			if err := xml.Unmarshal(buffer.Bytes(), &suites); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(suites.Suites) != len(test.suites) {
+				t.Fatalf("%d suites, want %d", len(suites.Suites), len(test.suites))
+			}
+			for _, suite := range suites.Suites {
+				want, ok := test.suites[suite.Name]
+				if !ok {
+					t.Errorf("Unexpected suite %s", suite.Name)
+					continue
+				}
+
+				failed := 0
+				for _, testCase := range suite.Cases {
+					if testCase.Failure != nil {
+						failed++
+					}
+				}
+				if suite.Tests != want[0] || suite.Failures != want[1] || len(suite.Cases) != want[0] || failed != want[1] {
+					t.Errorf("Suite %s has %d tests and %d failures, want %d and %d", suite.Name, suite.Tests, suite.Failures, want[0], want[1])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth   = 720
+	chartHeight  = 240
+	chartPadding = 48
+	barHeight    = 22
+	labelWidth   = 260
+)
+
+var palette = []string{"#2f6fb0", "#e0802b", "#3a9a5b", "#c23b3b", "#7d5bb5", "#8c6d3f"}
+
+type point struct {
+	x float64
+	y float64
+}
+
+type series struct {
+	name   string
+	points []point
+}
+
+type bar struct {
+	label string
+	value float64
+}
+
+// Line chart of one or more series sharing the axes
+func lineChart(xLabel, yLabel string, lines []*series) template.HTML {
+	var maxX, maxY float64
+	for _, line := range lines {
+		for _, p := range line.points {
+			maxX = math.Max(maxX, p.x)
+			maxY = math.Max(maxY, p.y)
+		}
+	}
+	if maxX == 0 {
+		maxX = 1
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+
+	plotWidth := float64(chartWidth - 2*chartPadding)
+	plotHeight := float64(chartHeight - 2*chartPadding)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	axes(&b, xLabel, yLabel, maxX, maxY)
+
+	for i, line := range lines {
+		color := palette[i%len(palette)]
+		coordinates := make([]string, 0, len(line.points))
+		for _, p := range line.points {
+			x := chartPadding + p.x/maxX*plotWidth
+			y := chartHeight - chartPadding - p.y/maxY*plotHeight
+			coordinates = append(coordinates, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(coordinates, " "))
+		fmt.Fprintf(&b, `<text x="%d" y="%d" fill="%s" font-size="12">%s</text>`, chartWidth-chartPadding-120, chartPadding-20+14*i, color, escape(line.name))
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func axes(b *strings.Builder, xLabel, yLabel string, maxX, maxY float64) {
+	left, bottom := chartPadding, chartHeight-chartPadding
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#444"/>`, left, bottom, chartWidth-chartPadding, bottom)
+	fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#444"/>`, left, chartPadding, left, bottom)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`, left-4, chartPadding+4, formatValue(maxY))
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11" text-anchor="end">0</text>`, left-4, bottom+4)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="11" text-anchor="end">%s</text>`, chartWidth-chartPadding, bottom+16, formatValue(maxX))
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="12" text-anchor="middle">%s</text>`, chartWidth/2, chartHeight-8, escape(xLabel))
+	fmt.Fprintf(b, `<text x="12" y="%d" font-size="12" transform="rotate(-90 12 %d)" text-anchor="middle">%s</text>`, chartHeight/2, chartHeight/2, escape(yLabel))
+}
+
+// Horizontal bar chart, which leaves room for long labels such as node addresses
+func barChart(unit string, bars []*bar) template.HTML {
+	var maxValue float64
+	for _, entry := range bars {
+		maxValue = math.Max(maxValue, entry.value)
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+
+	height := len(bars)*barHeight + 8
+	plotWidth := float64(chartWidth - labelWidth - 90)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, chartWidth, height, chartWidth, height)
+
+	for i, entry := range bars {
+		y := 4 + i*barHeight
+		width := entry.value / maxValue * plotWidth
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="end">%s</text>`, labelWidth-6, y+15, escape(shorten(entry.label, 40)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`, labelWidth, y+2, width, barHeight-6, palette[0])
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="12">%s %s</text>`, float64(labelWidth)+width+6, y+15, formatValue(entry.value), escape(unit))
+	}
+
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func escape(text string) string {
+	return template.HTMLEscapeString(text)
+}
+
+// Keeps the start and the end of long labels
+func shorten(label string, length int) string {
+	if len(label) <= length {
+		return label
+	}
+
+	half := (length - 3) / 2
+	return label[:half] + "..." + label[len(label)-half:]
+}
+
+func formatValue(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+
+	return fmt.Sprintf("%.2f", value)
+}
@@ -0,0 +1,66 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"millix-performance-test/load"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatNodesCSV = "nodes-csv"
+	FormatJUnit    = "junit"
+)
+
+// Writes a result in one output format
+type Writer interface {
+	Write(w io.Writer, res *load.Result) error
+	// Extension of the result path, unique among the formats
+	Extension() string
+}
+
+var writers = map[string]Writer{
+	FormatJSON:     &jsonWriter{},
+	FormatCSV:      &summaryCSVWriter{},
+	FormatNodesCSV: &nodesCSVWriter{},
+	FormatJUnit:    &junitWriter{},
+}
+
+func NewWriter(format string) (Writer, error) {
+	writer, ok := writers[format]
+	if !ok {
+		return nil, fmt.Errorf("Unknown output format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+
+	return writer, nil
+}
+
+func Formats() []string {
+	formats := make([]string, 0, len(writers))
+	for format := range writers {
+		formats = append(formats, format)
+	}
+
+	sort.Strings(formats)
+	return formats
+}
+
+type jsonWriter struct{}
+
+func (*jsonWriter) Write(w io.Writer, res *load.Result) error {
+	content, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(content)
+	return err
+}
+
+func (*jsonWriter) Extension() string {
+	return "json"
+}